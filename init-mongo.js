@@ -11,3 +11,4 @@ db.createUser(
     },
 );
 db.getCollection('urls').createIndex({ "url": 1 });
+db.getCollection('urls').createIndex({ "slug": 1 }, { unique: true });
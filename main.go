@@ -2,10 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"expvar"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/gofiber/fiber/v2"
@@ -20,6 +25,29 @@ import (
 
 const (
 	appName = "shrtnr"
+
+	storageMongo  = "mongo"
+	storageMemory = "memory"
+
+	sluggerRandom  = "random"
+	sluggerCounter = "counter"
+	sluggerHash    = "hash"
+
+	defaultCacheSize = 10000
+	defaultCacheTTL  = time.Minute
+
+	defaultHitsFlushInterval  = 5 * time.Second
+	defaultHitsFlushThreshold = 1000
+
+	defaultClicksFlushInterval  = 5 * time.Second
+	defaultExportTimeout        = 10 * time.Minute
+	defaultClicksFlushThreshold = 1000
+	defaultClicksBufferSize     = 100000
+	clickIPSaltSize             = 32
+
+	defaultRedirectStatus = http.StatusMovedPermanently
+
+	defaultBlocklistReloadInterval = 30 * time.Second
 )
 
 func main() {
@@ -32,35 +60,158 @@ func main() {
 func run() error {
 	log := logger.GetLoggerString(appName, "DEBUG")
 
-	mongoConf := repository.BuildMongoConfigs()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoConf.URI()))
+	// create store
+	var (
+		store   repository.Storer
+		counter repository.Counter
+		clicks  repository.ClickStorer
+	)
+	switch storage := os.Getenv("STORAGE"); storage {
+	case storageMemory:
+		store = repository.NewMemoryURLStorer()
+		counter = repository.NewMemoryCounter()
+		clicks = repository.NewMemoryClickStorer()
+	case storageMongo, "":
+		mongoConf := repository.BuildMongoConfigs()
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoConf.URI()))
+		if err != nil {
+			return fmt.Errorf("could not connect to mongodb: %w", err)
+		}
+		defer client.Disconnect(ctx) // nolint: errcheck
+
+		db := client.Database(mongoConf.DB)
+		coll := db.Collection(mongoConf.Collection)
+		mongoStore := repository.NewMongoDBURLStorer(coll)
+		err = mongoStore.EnsureIndexes(ctx)
+		if err != nil {
+			return fmt.Errorf("could not ensure mongodb indexes: %w", err)
+		}
+		store = mongoStore
+		counter = repository.NewMongoDBCounter(db.Collection(mongoConf.CountersCollection))
+		retention, err := envDuration("CLICKS_RETENTION", 0)
+		if err != nil {
+			return err
+		}
+		if retention < 0 {
+			return fmt.Errorf("CLICKS_RETENTION must not be negative, got %s", retention)
+		}
+		mongoClicks := repository.NewMongoDBClickStorer(db.Collection(mongoConf.ClicksCollection), db.Collection(mongoConf.VisitorsCollection), retention)
+		err = mongoClicks.EnsureIndexes(ctx)
+		if err != nil {
+			return fmt.Errorf("could not ensure mongodb clicks indexes: %w", err)
+		}
+		clicks = mongoClicks
+	default:
+		return fmt.Errorf("unknown STORAGE %q", storage)
+	}
+	// decorate store with a cache
+	cacheSize, err := envInt("CACHE_SIZE", defaultCacheSize)
 	if err != nil {
-		return fmt.Errorf("could not connect to mongodb: %w", err)
+		return err
+	}
+	cacheTTL, err := envDuration("CACHE_TTL", defaultCacheTTL)
+	if err != nil {
+		return err
+	}
+	if cacheSize > 0 {
+		cache := repository.NewCachedURLStorer(store, cacheSize, cacheTTL)
+		expvar.Publish("cache", expvar.Func(func() interface{} {
+			return cache.Stats()
+		}))
+		store = cache
 	}
-	defer client.Disconnect(ctx) // nolint: errcheck
-
-	db := client.Database(mongoConf.DB)
-
-	// create store
-	coll := db.Collection(mongoConf.Collection)
-	store := repository.NewMongoDBURLStorer(coll)
 
 	// create slugger
-	slugLen, err := strconv.Atoi(os.Getenv("SLUG_LEN"))
+	slugger, err := newSlugger(counter)
+	if err != nil {
+		return err
+	}
+	// create hit counter
+	flushInterval, err := envPositiveDuration("HITS_FLUSH_INTERVAL", defaultHitsFlushInterval)
+	if err != nil {
+		return err
+	}
+	flushThreshold, err := envInt("HITS_FLUSH_THRESHOLD", defaultHitsFlushThreshold)
+	if err != nil {
+		return err
+	}
+	hits := service.NewHitAggregator(store, flushInterval, flushThreshold)
+	go hits.Start(ctx)
+	// create click recorder
+	recorder, err := newClickRecorder(clicks, store)
+	if err != nil {
+		return err
+	}
+	go recorder.Start(ctx)
+	expvar.Publish("clicks", expvar.Func(func() interface{} {
+		return recorder.Stats()
+	}))
+	// create slug filter
+	filter, err := newSlugFilter()
+	if err != nil {
+		return err
+	}
+	// create vanity policy
+	vanity, err := newVanityPolicy()
+	if err != nil {
+		return err
+	}
+	// create url normalizer
+	normalizer, err := newURLNormalizer()
 	if err != nil {
-		return fmt.Errorf("could not parse SLUG_LEN: %w", err)
+		return err
+	}
+	// create url validator
+	validator := service.NewURLValidator(
+		envList("URL_SCHEMES", service.DefaultURLSchemes),
+		envList("PUBLIC_HOSTS", nil),
+	)
+	// create blocklist
+	opts := []service.Option{
+		service.WithSlugFilter(filter),
+		service.WithVanityPolicy(vanity),
+		service.WithURLNormalizer(normalizer),
+		service.WithURLValidator(validator),
+		service.WithClickStore(clicks),
+	}
+	if path := os.Getenv("URL_BLOCKLIST_FILE"); path != "" {
+		reloadInterval, err := envPositiveDuration("URL_BLOCKLIST_RELOAD_INTERVAL", defaultBlocklistReloadInterval)
+		if err != nil {
+			return err
+		}
+		blocklist, err := service.NewBlocklist(path, reloadInterval, log)
+		if err != nil {
+			return fmt.Errorf("could not load URL_BLOCKLIST_FILE: %w", err)
+		}
+		go blocklist.Start(ctx)
+		expvar.Publish("blocklist", expvar.Func(func() interface{} {
+			return blocklist.Stats()
+		}))
+		opts = append(opts, service.WithBlocklist(blocklist))
 	}
-	slugger := service.NewFixedLenSlugger(slugLen)
 	// create service
-	svc := service.NewURLService(store, slugger)
+	svc := service.NewURLService(store, slugger, hits, opts...)
+	expvar.Publish("slugs", expvar.Func(func() interface{} {
+		stats := map[string]interface{}{
+			"generation": svc.SlugStats(),
+		}
+		if growing, ok := slugger.(*service.GrowingSlugger); ok {
+			stats["length"] = growing.Len()
+		}
+		return stats
+	}))
 	// create server
 	port, err := strconv.Atoi(os.Getenv("PORT"))
 	if err != nil {
 		return fmt.Errorf("could not parse PORT: %w", err)
 	}
+	redirectStatus, err := envInt("REDIRECT_STATUS", defaultRedirectStatus)
+	if err != nil {
+		return err
+	}
 	app := fiber.New(fiber.Config{
 		CaseSensitive: true,
 		StrictRouting: true,
@@ -70,7 +221,22 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("could not find box: %w", err)
 	}
-	srv, err := server.NewHTTPServer(app, svc, port, box.HTTPBox(), log)
+	bots, err := newBotClassifier()
+	if err != nil {
+		return err
+	}
+	exportTimeout, err := envPositiveDuration("EXPORT_TIMEOUT", defaultExportTimeout)
+	if err != nil {
+		return err
+	}
+	srv, err := server.NewHTTPServer(app, svc, port, box.HTTPBox(), log,
+		server.WithShutdownHooks(hits.Shutdown, recorder.Shutdown),
+		server.WithRedirectStatus(redirectStatus),
+		server.WithClickRecorder(recorder),
+		server.WithCountryHeader(os.Getenv("COUNTRY_HEADER")),
+		server.WithBotClassifier(bots),
+		server.WithExportTimeout(exportTimeout),
+	)
 	if err != nil {
 		return fmt.Errorf("error while creating server: %w", err)
 	}
@@ -95,3 +261,241 @@ func run() error {
 	}
 	return nil
 }
+
+// newClickRecorder returns the recorder of the clicks, flushed every CLICKS_FLUSH_INTERVAL or CLICKS_FLUSH_THRESHOLD clicks,
+// buffering at most CLICKS_BUFFER_SIZE of them.
+// Visitor addresses are hashed with CLICK_IP_SALT, or else with a random salt,
+// which keeps visitors from being told apart across restarts, and so counts them again as unique visitors.
+func newClickRecorder(clicks repository.ClickStorer, urls repository.Storer) (*service.AsyncClickRecorder, error) {
+	interval, err := envPositiveDuration("CLICKS_FLUSH_INTERVAL", defaultClicksFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := envInt("CLICKS_FLUSH_THRESHOLD", defaultClicksFlushThreshold)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := envInt("CLICKS_BUFFER_SIZE", defaultClicksBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	salt := []byte(os.Getenv("CLICK_IP_SALT"))
+	if len(salt) == 0 {
+		salt = make([]byte, clickIPSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("could not generate click ip salt: %w", err)
+		}
+	}
+	return service.NewAsyncClickRecorder(clicks, urls, salt, interval, threshold, capacity), nil
+}
+
+// newSlugger returns the slugger selected by SLUGGER, either random (the default), counter or hash.
+func newSlugger(counter repository.Counter) (service.Slugger, error) {
+	slugLen, err := strconv.Atoi(os.Getenv("SLUG_LEN"))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse SLUG_LEN: %w", err)
+	}
+	alphabet := slugAlphabet(os.Getenv("SLUG_ALPHABET"))
+	switch kind := os.Getenv("SLUGGER"); kind {
+	case sluggerRandom, "":
+		slugMaxLen, err := envInt("SLUG_MAX_LEN", 2*slugLen)
+		if err != nil {
+			return nil, err
+		}
+		slugger, err := service.NewGrowingSlugger(alphabet, slugLen, slugMaxLen)
+		if err != nil {
+			return nil, fmt.Errorf("could not create slugger: %w", err)
+		}
+		return slugger, nil
+	case sluggerCounter:
+		key, err := strconv.ParseUint(os.Getenv("SLUG_KEY"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SLUG_KEY: %w", err)
+		}
+		slugger, err := service.NewCounterSlugger(counter, alphabet, slugLen, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not create slugger: %w", err)
+		}
+		return slugger, nil
+	case sluggerHash:
+		salt := os.Getenv("SLUG_SALT")
+		if salt == "" {
+			return nil, fmt.Errorf("SLUG_SALT is required with SLUGGER=%s", sluggerHash)
+		}
+		slugger, err := service.NewHashSlugger(alphabet, slugLen, salt)
+		if err != nil {
+			return nil, fmt.Errorf("could not create slugger: %w", err)
+		}
+		return slugger, nil
+	default:
+		return nil, fmt.Errorf("unknown SLUGGER %q", kind)
+	}
+}
+
+// newSlugFilter returns the slug filter rejecting the RESERVED_SLUGS, a comma separated list defaulting to the service ones,
+// and the slugs containing any of the words listed in the SLUG_BLOCKLIST_FILE, if any.
+func newSlugFilter() (service.SlugFilter, error) {
+	reserved := envList("RESERVED_SLUGS", service.DefaultReservedSlugs)
+	var blocked []string
+	if path := os.Getenv("SLUG_BLOCKLIST_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return service.SlugFilter{}, fmt.Errorf("could not open SLUG_BLOCKLIST_FILE: %w", err)
+		}
+		defer f.Close() // nolint: errcheck
+		blocked, err = service.ReadWords(f)
+		if err != nil {
+			return service.SlugFilter{}, fmt.Errorf("could not load SLUG_BLOCKLIST_FILE: %w", err)
+		}
+	}
+	return service.NewSlugFilter(reserved, blocked), nil
+}
+
+// newBotClassifier returns the classifier telling bots apart by the service rules
+// and the ones listed in the BOT_RULES_FILE, if any.
+func newBotClassifier() (*service.BotClassifier, error) {
+	rules := service.DefaultBotRules
+	if path := os.Getenv("BOT_RULES_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open BOT_RULES_FILE: %w", err)
+		}
+		defer f.Close() // nolint: errcheck
+		extra, err := service.ReadWords(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not load BOT_RULES_FILE: %w", err)
+		}
+		rules = append(append([]string{}, rules...), extra...)
+	}
+	return service.NewBotClassifier(rules), nil
+}
+
+// newVanityPolicy returns the policy of the slugs chosen by users,
+// made of the VANITY_SLUG_CHARSET letters, from VANITY_SLUG_MIN_LEN to VANITY_SLUG_MAX_LEN long
+// and turned to lowercase unless VANITY_SLUG_FOLD_CASE is false.
+func newVanityPolicy() (service.VanityPolicy, error) {
+	minLen, err := envInt("VANITY_SLUG_MIN_LEN", service.DefaultVanityMinLen)
+	if err != nil {
+		return service.VanityPolicy{}, err
+	}
+	maxLen, err := envInt("VANITY_SLUG_MAX_LEN", service.DefaultVanityMaxLen)
+	if err != nil {
+		return service.VanityPolicy{}, err
+	}
+	foldCase, err := envBool("VANITY_SLUG_FOLD_CASE", true)
+	if err != nil {
+		return service.VanityPolicy{}, err
+	}
+	charset := os.Getenv("VANITY_SLUG_CHARSET")
+	if charset == "" {
+		charset = service.AlphabetVanity
+	} else {
+		charset = slugAlphabet(charset)
+	}
+	policy, err := service.NewVanityPolicy(charset, minLen, maxLen, foldCase)
+	if err != nil {
+		return service.VanityPolicy{}, fmt.Errorf("could not create vanity policy: %w", err)
+	}
+	return policy, nil
+}
+
+// newURLNormalizer returns the url normalizer removing the fragments if STRIP_URL_FRAGMENT is true
+// and the tracking parameters if STRIP_TRACKING_PARAMS is true,
+// either the TRACKING_PARAMS, a comma separated list, or else the service ones.
+func newURLNormalizer() (service.URLNormalizer, error) {
+	stripFragment, err := envBool("STRIP_URL_FRAGMENT", false)
+	if err != nil {
+		return service.URLNormalizer{}, err
+	}
+	stripTracking, err := envBool("STRIP_TRACKING_PARAMS", false)
+	if err != nil {
+		return service.URLNormalizer{}, err
+	}
+	var tracking []string
+	if stripTracking {
+		tracking = envList("TRACKING_PARAMS", service.DefaultTrackingParams)
+	}
+	return service.NewURLNormalizer(stripFragment, tracking), nil
+}
+
+// slugAlphabet returns the slug alphabet named by name,
+// falling back to using name as the alphabet itself if it is not a known one.
+func slugAlphabet(name string) string {
+	switch name {
+	case "lowercase", "":
+		return service.AlphabetLowercase
+	case "base62":
+		return service.AlphabetBase62
+	case "base58":
+		return service.AlphabetBase58
+	default:
+		return name
+	}
+}
+
+// envInt parses the integer environment variable key, returning def if it is not set.
+func envInt(key string, def int) (int, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// envList parses the comma separated environment variable key, returning def if it is not set.
+func envList(key string, def []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envBool parses the boolean environment variable key, returning def if it is not set.
+func envBool(key string, def bool) (bool, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// envDuration parses the duration environment variable key, returning def if it is not set.
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// envPositiveDuration parses the duration environment variable key, returning def if it is not set.
+// Returns an error if the duration is not positive, e.g. since it sets the period of a ticker.
+func envPositiveDuration(key string, def time.Duration) (time.Duration, error) {
+	d, err := envDuration(key, def)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", key, d)
+	}
+	return d, nil
+}
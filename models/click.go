@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Click represents a redirect of a shortened url.
+// Visitors are never identified by their address, only by a salted hash of it.
+type Click struct {
+	// ID identifies the click, so that writing it again is harmless.
+	ID             string    `json:"id,omitempty"`
+	Slug           string    `json:"slug"`
+	Time           time.Time `json:"time"`
+	Referrer       string    `json:"referrer,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	IPHash         string    `json:"ip_hash,omitempty"`
+	AcceptLanguage string    `json:"accept_language,omitempty"`
+	RequestID      string    `json:"request_id,omitempty"`
+	// Browser is the family of the browser named by the user agent, e.g. Firefox.
+	Browser string `json:"browser,omitempty"`
+	// Country is the ISO 3166 code of the country of the visitor, if known.
+	Country string `json:"country,omitempty"`
+	// Bot reports whether the click was made by a bot or a crawler, e.g. a link previewer, rather than by a person.
+	Bot bool `json:"bot,omitempty"`
+}
@@ -1,8 +1,57 @@
 package models
 
+import (
+	"net/http"
+	"time"
+)
+
 // URLShortened represents the short version of a URL.
 type URLShortened struct {
 	URL  string `json:"url"`
 	Slug string `json:"slug"`
 	Hits int    `json:"hits"`
+	// BotHits is the number of redirects requested by bots and crawlers, they are not counted in Hits.
+	BotHits int `json:"bot_hits"`
+	// ExpiresAt is the time after which the shortened url stops redirecting, if set.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TTL is the lifetime in seconds requested on creation, it is turned into ExpiresAt and never stored.
+	TTL int64 `json:"ttl,omitempty"`
+	// MaxHits is the number of redirects after which the shortened url stops redirecting, if set.
+	MaxHits int `json:"max_hits,omitempty"`
+	// Password is the passphrase requested on creation, it is turned into PasswordHash and never stored.
+	Password string `json:"password,omitempty"`
+	// PasswordHash is the bcrypt hash of the passphrase required to resolve the shortened url, if set.
+	PasswordHash string `json:"-"`
+	// RedirectStatus is the HTTP status used to redirect to the original url, if set.
+	// Otherwise the server wide one is used.
+	RedirectStatus int `json:"redirect_status,omitempty"`
+	// FoldedSlug reports whether the slug was chosen by a user and stored in lowercase,
+	// so that it is found whatever the case it is typed in.
+	FoldedSlug bool `json:"-"`
+}
+
+// Expired reports whether the shortened url is expired at the given time.
+func (u URLShortened) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// Shareable reports whether the shortened url has no per link settings, so that it can be handed out
+// to everyone shortening the same url.
+func (u URLShortened) Shareable() bool {
+	return u.TTL == 0 && u.ExpiresAt == nil && u.MaxHits == 0 &&
+		u.Password == "" && u.PasswordHash == "" && u.RedirectStatus == 0
+}
+
+// Protected reports whether resolving the shortened url requires a passphrase.
+func (u URLShortened) Protected() bool {
+	return u.PasswordHash != ""
+}
+
+// ValidRedirectStatus reports whether the HTTP status can be used to redirect to an original url.
+func ValidRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// SketchPrecision is the number of hash bits picking the register of a Sketch.
+	SketchPrecision = 14
+	// SketchRegisters is the number of registers of a Sketch, its standard error is 1.04/sqrt(SketchRegisters), about 0.8%.
+	SketchRegisters = 1 << SketchPrecision
+	// maxRank is the highest rank a register can hold, the leading zeros of the remaining hash bits plus one.
+	maxRank = 64 - SketchPrecision + 1
+	// linearCountingThreshold is the estimate below which linear counting is more accurate than the raw HyperLogLog one,
+	// as measured by the HyperLogLog++ paper for this precision.
+	linearCountingThreshold = 11500
+)
+
+// Sketch is a HyperLogLog sketch estimating the number of distinct hashes added to it, e.g. of visitors,
+// without keeping the hashes themselves.
+// It maps register indexes to their rank and only keeps the registers that are set, so that sketches of a few hashes stay small.
+// Sketches must be created with NewSketch, or make.
+type Sketch map[int]uint8
+
+// NewSketch returns an empty Sketch.
+func NewSketch() Sketch {
+	return Sketch{}
+}
+
+// Add adds the hash, that must be uniformly distributed over 64 bits, to the sketch.
+func (s Sketch) Add(hash uint64) {
+	index := int(hash >> (64 - SketchPrecision))
+	rank := uint8(bits.LeadingZeros64(hash<<SketchPrecision) + 1)
+	if rank > maxRank {
+		rank = maxRank
+	}
+	s.Set(index, rank)
+}
+
+// Set raises the register at index to rank, unless it is already higher.
+// Merging registers this way makes adding the same hashes twice, or merging the same sketch twice, harmless.
+func (s Sketch) Set(index int, rank uint8) {
+	if rank > s[index] {
+		s[index] = rank
+	}
+}
+
+// Merge merges the other sketch into the sketch, which then estimates the union of both.
+func (s Sketch) Merge(other Sketch) {
+	for index, rank := range other {
+		s.Set(index, rank)
+	}
+}
+
+// Estimate returns the estimated number of distinct hashes added to the sketch.
+func (s Sketch) Estimate() uint64 {
+	zeros := SketchRegisters - len(s)
+	if zeros > 0 {
+		linear := SketchRegisters * math.Log(float64(SketchRegisters)/float64(zeros))
+		if linear <= linearCountingThreshold {
+			return uint64(math.Round(linear))
+		}
+	}
+	sum := float64(zeros) // 2^-0 for each register not set
+	for _, rank := range s {
+		sum += math.Ldexp(1, -int(rank))
+	}
+	alpha := 0.7213 / (1 + 1.079/SketchRegisters)
+	return uint64(math.Round(alpha * SketchRegisters * SketchRegisters / sum))
+}
+
+// DailySketch is the Sketch of the visitors of a shortened url during a day.
+type DailySketch struct {
+	Slug string
+	// Day is the start of the day, in UTC.
+	Day    time.Time
+	Sketch Sketch
+}
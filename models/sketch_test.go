@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mix returns a uniformly distributed hash of i, the finalizer of splitmix64.
+func mix(i uint64) uint64 {
+	i += 0x9e3779b97f4a7c15
+	i = (i ^ (i >> 30)) * 0xbf58476d1ce4e5b9
+	i = (i ^ (i >> 27)) * 0x94d049bb133111eb
+	return i ^ (i >> 31)
+}
+
+func TestSketch_Estimate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		distinct  uint64
+		tolerance float64
+	}{
+		{name: "empty", distinct: 0, tolerance: 0},
+		{name: "one", distinct: 1, tolerance: 0},
+		{name: "hundred", distinct: 100, tolerance: 0.01},
+		{name: "ten thousand", distinct: 10000, tolerance: 0.03},
+		{name: "hundred thousand", distinct: 100000, tolerance: 0.03},
+		{name: "million", distinct: 1000000, tolerance: 0.03},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			sketch := NewSketch()
+			for i := uint64(0); i < tt.distinct; i++ {
+				sketch.Add(mix(i))
+				sketch.Add(mix(i)) // duplicates are not counted
+			}
+			got := sketch.Estimate()
+			require.InDelta(t, float64(tt.distinct), float64(got), math.Max(tt.tolerance*float64(tt.distinct), 0.5),
+				"estimated %d, want %d", got, tt.distinct)
+			require.LessOrEqual(t, len(sketch), SketchRegisters)
+		})
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	t.Parallel()
+	a, b, union := NewSketch(), NewSketch(), NewSketch()
+	for i := uint64(0); i < 30000; i++ {
+		if i < 20000 {
+			a.Add(mix(i))
+		}
+		if i >= 10000 {
+			b.Add(mix(i))
+		}
+		union.Add(mix(i))
+	}
+
+	a.Merge(b)
+	require.Equal(t, union, a)
+	a.Merge(b) // merging twice is harmless
+	require.Equal(t, union, a)
+}
+
+func TestSketch_Add(t *testing.T) {
+	t.Parallel()
+	sketch := NewSketch()
+	sketch.Add(0)                                                 // every hash bit is zero, the rank is capped
+	sketch.Add(1<<(64-SketchPrecision) | 1<<(62-SketchPrecision)) // register 1, rank 2
+	sketch.Add(1<<(64-SketchPrecision) | 1<<(63-SketchPrecision)) // register 1, a lower rank is ignored
+	sketch.Add(^uint64(0))
+	require.Equal(t, Sketch{0: maxRank, 1: 2, SketchRegisters - 1: 1}, sketch)
+}
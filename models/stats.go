@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// StatsInterval is the width of the buckets clicks are counted in.
+type StatsInterval string
+
+const (
+	// IntervalHour counts clicks per hour.
+	IntervalHour StatsInterval = "hour"
+	// IntervalDay counts clicks per day, in UTC.
+	IntervalDay StatsInterval = "day"
+	// IntervalWeek counts clicks per week, in UTC, starting on Monday.
+	IntervalWeek StatsInterval = "week"
+)
+
+// IntervalOrigin is the time buckets are aligned to, the first Monday of the Unix epoch.
+var IntervalOrigin = time.Date(1970, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+// Duration returns the width of the interval, or zero if the interval is not valid.
+func (i StatsInterval) Duration() time.Duration {
+	switch i {
+	case IntervalHour:
+		return time.Hour
+	case IntervalDay:
+		return 24 * time.Hour
+	case IntervalWeek:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// Truncate returns the start of the bucket of the interval t belongs to.
+func (i StatsInterval) Truncate(t time.Time) time.Time {
+	d := i.Duration()
+	offset := t.Sub(IntervalOrigin) % d
+	if offset < 0 {
+		offset += d
+	}
+	return t.Add(-offset).UTC()
+}
+
+// ClickStats are the statistics of the clicks on a shortened url in a time range.
+type ClickStats struct {
+	Slug     string        `json:"slug"`
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Interval StatsInterval `json:"interval"`
+	Total    int           `json:"total"`
+	// Bots is the number of clicks made by bots and crawlers, they are left out of all the other statistics.
+	Bots int `json:"bots"`
+	// Buckets are the clicks per interval, from the one of From to the one of To, including the ones without clicks.
+	Buckets []ClickBucket `json:"buckets"`
+	// Referrers, UserAgents, Browsers and Countries are the most frequent values among the clicks having them, most frequent first.
+	Referrers  []Tally `json:"referrers"`
+	UserAgents []Tally `json:"user_agents"`
+	Browsers   []Tally `json:"browsers"`
+	Countries  []Tally `json:"countries"`
+	// Visitors is the approximate number of unique visitors of the shortened url, ever.
+	Visitors uint64 `json:"visitors"`
+	// DailyVisitors are the approximate unique visitors per day, from the day of From to the one of To,
+	// including the days without visitors, over the last 1000 days at most.
+	DailyVisitors []VisitorBucket `json:"daily_visitors"`
+}
+
+// VisitorBucket is the approximate number of unique visitors in the day starting at Day.
+type VisitorBucket struct {
+	Day      time.Time `json:"day"`
+	Visitors uint64    `json:"visitors"`
+}
+
+// ClickBucket is the number of clicks in the interval starting at Start.
+type ClickBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// Tally is the number of clicks having a value.
+type Tally struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
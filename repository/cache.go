@@ -0,0 +1,294 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+)
+
+// CacheStats reports how effective a CachedURLStorer is.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// CachedURLStorer implements the Storer decorating another Storer with a read-through cache.
+// Get and GetURL are served from a bounded LRU cache whose entries expire after a TTL,
+// writes go to the decorated Storer and invalidate the cached entries.
+// Hit counters of cached entries are kept up to date on a best effort basis, so they may lag behind until the entries expire.
+// It is safe for concurrent use.
+type CachedURLStorer struct {
+	store Storer
+	size  int
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu         sync.Mutex
+	lru        *list.List               // most recently used at the front
+	slugs      map[string]*list.Element // slug -> element holding a cacheEntry
+	urls       map[string]string        // url -> slug
+	generation uint64                   // bumped on every invalidation
+
+	hits, misses, evictions uint64
+}
+
+type cacheEntry struct {
+	short   models.URLShortened
+	expires time.Time
+}
+
+// NewCachedURLStorer returns a new instance of a CachedURLStorer caching up to size entries for ttl.
+func NewCachedURLStorer(store Storer, size int, ttl time.Duration) *CachedURLStorer {
+	return &CachedURLStorer{
+		store: store,
+		size:  size,
+		ttl:   ttl,
+		now:   time.Now,
+		lru:   list.New(),
+		slugs: map[string]*list.Element{},
+		urls:  map[string]string{},
+	}
+}
+
+// Stats returns the cache statistics.
+func (c *CachedURLStorer) Stats() CacheStats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Size:      size,
+	}
+}
+
+// Add adds a shortened url to the decorated repository.
+// Returns an error if any.
+func (c *CachedURLStorer) Add(ctx context.Context, shortened models.URLShortened) error {
+	return c.store.Add(ctx, shortened)
+}
+
+// Get gets a original url using the slug, from the cache if possible.
+// Returns an error if any.
+func (c *CachedURLStorer) Get(ctx context.Context, slug string) (models.URLShortened, error) {
+	c.mu.Lock()
+	short, ok := c.lookup(slug)
+	gen := c.generation
+	c.mu.Unlock()
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+		return short, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	short, err := c.store.Get(ctx, slug)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	c.put(gen, short, false)
+	return short, nil
+}
+
+// GetURL gets a shortened url, from the cache if possible.
+// Returns an error if any.
+func (c *CachedURLStorer) GetURL(ctx context.Context, url string) (models.URLShortened, error) {
+	c.mu.Lock()
+	short, ok := c.lookup(c.urls[url])
+	gen := c.generation
+	c.mu.Unlock()
+	if ok && short.URL == url {
+		atomic.AddUint64(&c.hits, 1)
+		return short, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	short, err := c.store.GetURL(ctx, url)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	c.put(gen, short, true)
+	return short, nil
+}
+
+// GetShareableURL gets the shareable shortened url of the url, from the cache if possible.
+// Returns an error if any.
+func (c *CachedURLStorer) GetShareableURL(ctx context.Context, url string) (models.URLShortened, error) {
+	c.mu.Lock()
+	short, ok := c.lookup(c.urls[url])
+	gen := c.generation
+	c.mu.Unlock()
+	if ok && short.URL == url && short.Shareable() {
+		atomic.AddUint64(&c.hits, 1)
+		return short, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+	short, err := c.store.GetShareableURL(ctx, url)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	c.put(gen, short, true)
+	return short, nil
+}
+
+// Update updates a shortened url in the decorated repository and invalidates the cached copy.
+// Returns an error if any.
+func (c *CachedURLStorer) Update(ctx context.Context, newshort models.URLShortened) error {
+	c.invalidate(newshort.Slug, newshort.URL)
+	err := c.store.Update(ctx, newshort)
+	c.invalidate(newshort.Slug, newshort.URL)
+	return err
+}
+
+// IncrementHits increments the hit counter in the decorated repository and in the cached copy.
+// Returns an error if any.
+func (c *CachedURLStorer) IncrementHits(ctx context.Context, slug string, delta int) error {
+	err := c.store.IncrementHits(ctx, slug, delta)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.incrementHits(slug, delta)
+	c.mu.Unlock()
+	return nil
+}
+
+// BulkIncrementHits increments the hit counters in the decorated repository and in the cached copies.
+// Returns an error if any.
+func (c *CachedURLStorer) BulkIncrementHits(ctx context.Context, deltas map[string]int) error {
+	err := c.store.BulkIncrementHits(ctx, deltas)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	for slug, delta := range deltas {
+		c.incrementHits(slug, delta)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// BulkIncrementBotHits increments the bot hit counters in the decorated repository and in the cached copies.
+// Returns an error if any.
+func (c *CachedURLStorer) BulkIncrementBotHits(ctx context.Context, deltas map[string]int) error {
+	err := c.store.BulkIncrementBotHits(ctx, deltas)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	for slug, delta := range deltas {
+		if el, ok := c.slugs[slug]; ok {
+			el.Value.(*cacheEntry).short.BotHits += delta
+		}
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// ConsumeHit consumes a hit in the decorated repository and invalidates the cached copy.
+// Returns the updated shortened url or an error if any.
+func (c *CachedURLStorer) ConsumeHit(ctx context.Context, slug string) (models.URLShortened, error) {
+	short, err := c.store.ConsumeHit(ctx, slug)
+	c.invalidate(slug, "")
+	return short, err
+}
+
+// Delete deletes a shortened url from the decorated repository and invalidates the cached copy.
+// Returns an error if any.
+func (c *CachedURLStorer) Delete(ctx context.Context, slug string) error {
+	c.invalidate(slug, "")
+	err := c.store.Delete(ctx, slug)
+	c.invalidate(slug, "")
+	return err
+}
+
+// MergeVisitors merges the sketches of the visitors in the decorated repository, they are never cached.
+// Returns an error if any.
+func (c *CachedURLStorer) MergeVisitors(ctx context.Context, sketches map[string]models.Sketch) error {
+	return c.store.MergeVisitors(ctx, sketches)
+}
+
+// Visitors returns the sketch of the visitors from the decorated repository, they are never cached.
+// Returns an error if any.
+func (c *CachedURLStorer) Visitors(ctx context.Context, slug string) (models.Sketch, error) {
+	return c.store.Visitors(ctx, slug)
+}
+
+// lookup returns the cached entry for the slug if present and not expired, marking it as recently used.
+// It must be called holding the lock.
+func (c *CachedURLStorer) lookup(slug string) (models.URLShortened, bool) {
+	el, ok := c.slugs[slug]
+	if !ok {
+		return models.URLShortened{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.remove(el)
+		return models.URLShortened{}, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.short, true
+}
+
+// put caches the shortened url, unless the cache was invalidated since gen was read.
+// This prevents a slow read racing with a write from caching stale data.
+// The url lookup entry is only cached if the shortened url was returned by a url lookup.
+func (c *CachedURLStorer) put(gen uint64, short models.URLShortened, byURL bool) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.generation {
+		return
+	}
+	if el, ok := c.slugs[short.Slug]; ok {
+		c.remove(el)
+	}
+	c.slugs[short.Slug] = c.lru.PushFront(&cacheEntry{
+		short:   short,
+		expires: c.now().Add(c.ttl),
+	})
+	if byURL {
+		c.urls[short.URL] = short.Slug
+	}
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// invalidate drops the cached entries for the slug and the url.
+func (c *CachedURLStorer) invalidate(slug, url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if el, ok := c.slugs[slug]; ok {
+		c.remove(el)
+	}
+	if url != "" {
+		delete(c.urls, url)
+	}
+}
+
+// incrementHits increments the hit counter of the cached entry, if any.
+// It must be called holding the lock.
+func (c *CachedURLStorer) incrementHits(slug string, delta int) {
+	if el, ok := c.slugs[slug]; ok {
+		el.Value.(*cacheEntry).short.Hits += delta
+	}
+}
+
+// remove drops the element from the cache along with its url lookup entry.
+// It must be called holding the lock.
+func (c *CachedURLStorer) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.slugs, entry.short.Slug)
+	if c.urls[entry.short.URL] == entry.short.Slug {
+		delete(c.urls, entry.short.URL)
+	}
+}
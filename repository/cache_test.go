@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedURLStorer_Get(t *testing.T) {
+	t.Parallel()
+	short := models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"}
+	tests := []struct {
+		name              string
+		setupExpectations func(store *MockStorer)
+		run               func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error
+		wantStats         CacheStats
+	}{
+		{
+			name: "Happy path - second get is served from the cache",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(short, nil).Times(1)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				for i := 0; i < 2; i++ {
+					url, err := cache.Get(ctx, "aeiou")
+					if err != nil {
+						return err
+					}
+					if url != short {
+						return errors.New("unexpected url")
+					}
+				}
+				return nil
+			},
+			wantStats: CacheStats{Hits: 1, Misses: 1, Size: 1},
+		},
+		{
+			name: "Happy path - get url is served from the cache",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().GetURL(gomock.Any(), "https://shrtnr.dev").Return(short, nil).Times(1)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				for i := 0; i < 2; i++ {
+					_, err := cache.GetURL(ctx, "https://shrtnr.dev")
+					if err != nil {
+						return err
+					}
+				}
+				_, err := cache.Get(ctx, "aeiou")
+				return err
+			},
+			wantStats: CacheStats{Hits: 2, Misses: 1, Size: 1},
+		},
+		{
+			name: "Happy path - expired entries are fetched again",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(short, nil).Times(2)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				_, err := cache.Get(ctx, "aeiou")
+				if err != nil {
+					return err
+				}
+				*now = now.Add(2 * time.Minute)
+				_, err = cache.Get(ctx, "aeiou")
+				return err
+			},
+			wantStats: CacheStats{Hits: 0, Misses: 2, Size: 1},
+		},
+		{
+			name: "Happy path - least recently used entry is evicted",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(short, nil).Times(1)
+				store.EXPECT().Get(gomock.Any(), "pizza").Return(models.URLShortened{URL: "https://pizza.com", Slug: "pizza"}, nil).Times(2)
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{URL: "https://short.com", Slug: "short"}, nil).Times(1)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				for _, slug := range []string{"aeiou", "pizza", "aeiou", "short", "aeiou", "pizza"} {
+					_, err := cache.Get(ctx, slug)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantStats: CacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2},
+		},
+		{
+			name: "Happy path - update invalidates the cached entry",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(short, nil).Times(2)
+				store.EXPECT().Update(gomock.Any(), short).Return(nil)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				_, err := cache.Get(ctx, "aeiou")
+				if err != nil {
+					return err
+				}
+				err = cache.Update(ctx, short)
+				if err != nil {
+					return err
+				}
+				_, err = cache.Get(ctx, "aeiou")
+				return err
+			},
+			wantStats: CacheStats{Hits: 0, Misses: 2, Size: 1},
+		},
+		{
+			name: "Happy path - delete invalidates the cached entry",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(short, nil).Times(1)
+				store.EXPECT().Delete(gomock.Any(), "aeiou").Return(nil)
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(models.URLShortened{}, ErrSlugNotFound).Times(1)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				_, err := cache.Get(ctx, "aeiou")
+				if err != nil {
+					return err
+				}
+				err = cache.Delete(ctx, "aeiou")
+				if err != nil {
+					return err
+				}
+				_, err = cache.Get(ctx, "aeiou")
+				if !errors.Is(err, ErrSlugNotFound) {
+					return errors.New("deleted entry served from the cache")
+				}
+				return nil
+			},
+			wantStats: CacheStats{Hits: 0, Misses: 2, Size: 0},
+		},
+		{
+			name: "Happy path - hits are incremented on the cached entry",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(short, nil).Times(1)
+				store.EXPECT().BulkIncrementHits(gomock.Any(), map[string]int{"aeiou": 2}).Return(nil)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				_, err := cache.Get(ctx, "aeiou")
+				if err != nil {
+					return err
+				}
+				err = cache.BulkIncrementHits(ctx, map[string]int{"aeiou": 2})
+				if err != nil {
+					return err
+				}
+				url, err := cache.Get(ctx, "aeiou")
+				if err != nil {
+					return err
+				}
+				if url.Hits != 2 {
+					return errors.New("stale hits served from the cache")
+				}
+				return nil
+			},
+			wantStats: CacheStats{Hits: 1, Misses: 1, Size: 1},
+		},
+		{
+			name: "Sad path - errors are not cached",
+			setupExpectations: func(store *MockStorer) {
+				store.EXPECT().Get(gomock.Any(), "aeiou").Return(models.URLShortened{}, ErrSlugNotFound).Times(2)
+			},
+			run: func(ctx context.Context, cache *CachedURLStorer, now *time.Time) error {
+				for i := 0; i < 2; i++ {
+					_, err := cache.Get(ctx, "aeiou")
+					if !errors.Is(err, ErrSlugNotFound) {
+						return errors.New("unexpected error")
+					}
+				}
+				return nil
+			},
+			wantStats: CacheStats{Hits: 0, Misses: 2, Size: 0},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStore := NewMockStorer(ctrl)
+			tt.setupExpectations(mockStore)
+
+			now := time.Now()
+			cache := NewCachedURLStorer(mockStore, 2, time.Minute)
+			cache.now = func() time.Time { return now }
+
+			err := tt.run(context.Background(), cache, &now)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStats, cache.Stats())
+		})
+	}
+}
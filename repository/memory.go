@@ -0,0 +1,460 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+)
+
+// MemoryURLStorer implements the Storer keeping shortened urls in memory.
+// It is safe for concurrent use.
+type MemoryURLStorer struct {
+	mu       sync.RWMutex
+	slugs    map[string]models.URLShortened // slug -> shortened url
+	urls     map[string]string              // url -> slug
+	visitors map[string]models.Sketch       // slug -> visitors
+}
+
+// NewMemoryURLStorer returns a new instance of a MemoryURLStorer.
+func NewMemoryURLStorer() *MemoryURLStorer {
+	return &MemoryURLStorer{
+		slugs:    map[string]models.URLShortened{},
+		urls:     map[string]string{},
+		visitors: map[string]models.Sketch{},
+	}
+}
+
+// Add adds a shortened url to the in memory repository.
+// Returns an error if any.
+func (m *MemoryURLStorer) Add(ctx context.Context, shortened models.URLShortened) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.slugs[shortened.Slug]; ok {
+		return fmt.Errorf("could not add: %w", ErrSlugAlreadyInUse)
+	}
+	m.slugs[shortened.Slug] = shortened
+	if _, ok := m.urls[shortened.URL]; !ok {
+		m.urls[shortened.URL] = shortened.Slug
+	}
+	return nil
+}
+
+// Get gets a original url using the slug from the in memory repository.
+// Returns an error if any.
+func (m *MemoryURLStorer) Get(ctx context.Context, slug string) (models.URLShortened, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	shortURL, ok := m.slugs[slug]
+	if !ok {
+		return models.URLShortened{}, ErrSlugNotFound
+	}
+	return shortURL, nil
+}
+
+// GetURL gets a shortened url from the in memory repository.
+// Returns an error if any.
+func (m *MemoryURLStorer) GetURL(ctx context.Context, url string) (models.URLShortened, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	slug, ok := m.urls[url]
+	if !ok {
+		return models.URLShortened{}, ErrURLNotFound
+	}
+	return m.slugs[slug], nil
+}
+
+// GetShareableURL gets the shareable shortened url of the url, the one without per link settings,
+// from the in memory repository.
+// Returns an error if any.
+func (m *MemoryURLStorer) GetShareableURL(ctx context.Context, url string) (models.URLShortened, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if short, ok := m.slugs[m.urls[url]]; ok && short.Shareable() {
+		return short, nil
+	}
+	for _, short := range m.slugs {
+		if short.URL == url && short.Shareable() {
+			return short, nil
+		}
+	}
+	return models.URLShortened{}, ErrURLNotFound
+}
+
+// Update updates a shortened url in the in memory repository.
+// Returns an error if any.
+func (m *MemoryURLStorer) Update(ctx context.Context, newshort models.URLShortened) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.slugs[newshort.Slug]
+	if !ok {
+		return fmt.Errorf("could not update: %w", ErrSlugNotFound)
+	}
+	m.slugs[newshort.Slug] = newshort
+	if old.URL != newshort.URL {
+		m.unindex(old)
+		if _, ok := m.urls[newshort.URL]; !ok {
+			m.urls[newshort.URL] = newshort.Slug
+		}
+	}
+	return nil
+}
+
+// IncrementHits atomically increments by delta the hit counter of the shortened url identified by the slug.
+// Returns an error if any.
+func (m *MemoryURLStorer) IncrementHits(ctx context.Context, slug string, delta int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	short, ok := m.slugs[slug]
+	if !ok {
+		return fmt.Errorf("could not increment hits: %w", ErrSlugNotFound)
+	}
+	short.Hits += delta
+	m.slugs[slug] = short
+	return nil
+}
+
+// BulkIncrementHits atomically increments the hit counters of many shortened urls.
+// The deltas map slugs to their increment, slugs that could not be found are skipped.
+// Returns an error if any.
+func (m *MemoryURLStorer) BulkIncrementHits(ctx context.Context, deltas map[string]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for slug, delta := range deltas {
+		short, ok := m.slugs[slug]
+		if !ok {
+			continue
+		}
+		short.Hits += delta
+		m.slugs[slug] = short
+	}
+	return nil
+}
+
+// BulkIncrementBotHits atomically increments the bot hit counters of many shortened urls.
+// The deltas map slugs to their increment, slugs that could not be found are skipped.
+// Returns an error if any.
+func (m *MemoryURLStorer) BulkIncrementBotHits(ctx context.Context, deltas map[string]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for slug, delta := range deltas {
+		short, ok := m.slugs[slug]
+		if !ok {
+			continue
+		}
+		short.BotHits += delta
+		m.slugs[slug] = short
+	}
+	return nil
+}
+
+// ConsumeHit atomically increments by one the hit counter of the shortened url identified by the slug,
+// unless it already reached its max hits.
+// Returns the updated shortened url or an error if any.
+func (m *MemoryURLStorer) ConsumeHit(ctx context.Context, slug string) (models.URLShortened, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	short, ok := m.slugs[slug]
+	if !ok {
+		return models.URLShortened{}, fmt.Errorf("could not consume hit: %w", ErrSlugNotFound)
+	}
+	if short.MaxHits > 0 && short.Hits >= short.MaxHits {
+		return models.URLShortened{}, fmt.Errorf("could not consume hit: %w", ErrHitLimitReached)
+	}
+	short.Hits++
+	m.slugs[slug] = short
+	return short, nil
+}
+
+// Delete deletes a shortened url from the in memory repository.
+// Returns an error if any.
+func (m *MemoryURLStorer) Delete(ctx context.Context, slug string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.slugs[slug]
+	if !ok {
+		return fmt.Errorf("could not delete: %w", ErrSlugNotFound)
+	}
+	delete(m.slugs, slug)
+	delete(m.visitors, slug)
+	m.unindex(old)
+	return nil
+}
+
+// MergeVisitors merges the sketches into the ones of the visitors of many shortened urls.
+// The sketches map slugs to the visitors to merge, slugs that could not be found are skipped.
+// Returns an error if any.
+func (m *MemoryURLStorer) MergeVisitors(ctx context.Context, sketches map[string]models.Sketch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for slug, sketch := range sketches {
+		if _, ok := m.slugs[slug]; !ok {
+			continue
+		}
+		visitors, ok := m.visitors[slug]
+		if !ok {
+			visitors = models.NewSketch()
+			m.visitors[slug] = visitors
+		}
+		visitors.Merge(sketch)
+	}
+	return nil
+}
+
+// Visitors returns a copy of the sketch of the visitors of the shortened url identified by the slug.
+// Returns an error if any.
+func (m *MemoryURLStorer) Visitors(ctx context.Context, slug string) (models.Sketch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.slugs[slug]; !ok {
+		return nil, fmt.Errorf("could not get visitors: %w", ErrSlugNotFound)
+	}
+	visitors := models.NewSketch()
+	visitors.Merge(m.visitors[slug])
+	return visitors, nil
+}
+
+// unindex removes the url lookup entry pointing to the input shortened url,
+// promoting another slug shortening the same url if there is one.
+// It must be called holding the write lock.
+func (m *MemoryURLStorer) unindex(old models.URLShortened) {
+	if m.urls[old.URL] != old.Slug {
+		return
+	}
+	delete(m.urls, old.URL)
+	for slug, short := range m.slugs {
+		if short.URL == old.URL {
+			m.urls[old.URL] = slug
+			return
+		}
+	}
+}
+
+// MemoryCounter implements the Counter keeping the counters in memory, they start over on restart.
+// It is safe for concurrent use.
+type MemoryCounter struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewMemoryCounter returns a new instance of a MemoryCounter.
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{
+		counters: map[string]uint64{},
+	}
+}
+
+// Next increments the counter identified by the name, returning its new value.
+// Counters start from 1.
+// Returns an error if any.
+func (m *MemoryCounter) Next(ctx context.Context, name string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+	return m.counters[name], nil
+}
+
+// MemoryClickStorer implements the ClickStorer keeping clicks in memory.
+// It is safe for concurrent use.
+type MemoryClickStorer struct {
+	mu     sync.RWMutex
+	clicks []models.Click // in chronological order
+	ids    map[string]struct{}
+	daily  map[dailyKey]models.Sketch
+}
+
+// dailyKey identifies the daily sketch of the visitors of a slug.
+type dailyKey struct {
+	slug string
+	day  int64 // unix time of the start of the day
+}
+
+// NewMemoryClickStorer returns a new instance of a MemoryClickStorer.
+func NewMemoryClickStorer() *MemoryClickStorer {
+	return &MemoryClickStorer{
+		ids:   map[string]struct{}{},
+		daily: map[dailyKey]models.Sketch{},
+	}
+}
+
+// AddClicks adds the clicks to the in memory repository, skipping the ones already stored.
+// Returns an error if any.
+func (m *MemoryClickStorer) AddClicks(ctx context.Context, clicks []models.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, click := range clicks {
+		if click.ID != "" {
+			if _, ok := m.ids[click.ID]; ok {
+				continue
+			}
+			m.ids[click.ID] = struct{}{}
+		}
+		m.clicks = append(m.clicks, click)
+	}
+	byTime := func(i, j int) bool {
+		return m.clicks[i].Time.Before(m.clicks[j].Time)
+	}
+	if !sort.SliceIsSorted(m.clicks, byTime) { // clicks mostly come in order
+		sort.SliceStable(m.clicks, byTime)
+	}
+	return nil
+}
+
+// IterateClicks calls fn with the clicks selected by the query, in chronological order.
+// Clicks are iterated over a copy of the selected ones, so that fn can take its time without blocking writes.
+// Returns an error if any.
+func (m *MemoryClickStorer) IterateClicks(ctx context.Context, query ClickQuery, fn func(models.Click) error) error {
+	m.mu.RLock()
+	var selected []models.Click
+	for _, click := range m.clicks {
+		if query.Match(click) {
+			selected = append(selected, click)
+		}
+	}
+	m.mu.RUnlock()
+	for _, click := range selected {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("could not iterate clicks: %w", err)
+		}
+		if err := fn(click); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClickStats aggregates the clicks selected by the query into statistics.
+// Returns an error if any.
+func (m *MemoryClickStorer) ClickStats(ctx context.Context, query ClickQuery, interval models.StatsInterval, top int) (models.ClickStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := models.ClickStats{}
+	buckets := map[time.Time]int{}
+	referrers, userAgents, browsers, countries := tallies{}, tallies{}, tallies{}, tallies{}
+	for _, click := range m.clicks {
+		if !query.Match(click) {
+			continue
+		}
+		if click.Bot {
+			stats.Bots++
+			continue
+		}
+		stats.Total++
+		buckets[interval.Truncate(click.Time)]++
+		referrers.add(click.Referrer)
+		userAgents.add(click.UserAgent)
+		browsers.add(click.Browser)
+		countries.add(click.Country)
+	}
+	for start, count := range buckets {
+		stats.Buckets = append(stats.Buckets, models.ClickBucket{Start: start, Count: count})
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		return stats.Buckets[i].Start.Before(stats.Buckets[j].Start)
+	})
+	stats.Referrers = referrers.top(top)
+	stats.UserAgents = userAgents.top(top)
+	stats.Browsers = browsers.top(top)
+	stats.Countries = countries.top(top)
+	return stats, nil
+}
+
+// tallies counts the clicks per value.
+type tallies map[string]int
+
+// add counts a click with the value, unless it is empty.
+func (t tallies) add(value string) {
+	if value != "" {
+		t[value]++
+	}
+}
+
+// top returns the n most frequent values, ties broken by value, none if n is not positive.
+func (t tallies) top(n int) []models.Tally {
+	if n <= 0 {
+		return []models.Tally{}
+	}
+	all := make([]models.Tally, 0, len(t))
+	for value, count := range t {
+		all = append(all, models.Tally{Value: value, Count: count})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Count != all[j].Count {
+			return all[i].Count > all[j].Count
+		}
+		return all[i].Value < all[j].Value
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// MergeDailyVisitors merges the sketches into the stored ones of the same slug and day.
+// Returns an error if any.
+func (m *MemoryClickStorer) MergeDailyVisitors(ctx context.Context, sketches []models.DailySketch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sketch := range sketches {
+		key := dailyKey{slug: sketch.Slug, day: sketch.Day.Unix()}
+		visitors, ok := m.daily[key]
+		if !ok {
+			visitors = models.NewSketch()
+			m.daily[key] = visitors
+		}
+		visitors.Merge(sketch.Sketch)
+	}
+	return nil
+}
+
+// DailyVisitors returns copies of the daily sketches of the visitors selected by the query, in chronological order.
+// Returns an error if any.
+func (m *MemoryClickStorer) DailyVisitors(ctx context.Context, query ClickQuery) ([]models.DailySketch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var sketches []models.DailySketch
+	for key, visitors := range m.daily {
+		day := time.Unix(key.day, 0).UTC()
+		if !query.matches(key.slug, day) {
+			continue
+		}
+		sketch := models.NewSketch()
+		sketch.Merge(visitors)
+		sketches = append(sketches, models.DailySketch{Slug: key.slug, Day: day, Sketch: sketch})
+	}
+	sort.Slice(sketches, func(i, j int) bool {
+		if !sketches[i].Day.Equal(sketches[j].Day) {
+			return sketches[i].Day.Before(sketches[j].Day)
+		}
+		return sketches[i].Slug < sketches[j].Slug
+	})
+	return sketches, nil
+}
+
+// DeleteClicks deletes the clicks and the daily sketches of the visitors of the slug from the in memory repository.
+// Returns an error if any.
+func (m *MemoryClickStorer) DeleteClicks(ctx context.Context, slug string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.clicks[:0]
+	for _, click := range m.clicks {
+		if click.Slug != slug {
+			kept = append(kept, click)
+			continue
+		}
+		delete(m.ids, click.ID)
+	}
+	for i := len(kept); i < len(m.clicks); i++ {
+		m.clicks[i] = models.Click{} // let the deleted ones be collected
+	}
+	m.clicks = kept
+	for key := range m.daily {
+		if key.slug == slug {
+			delete(m.daily, key)
+		}
+	}
+	return nil
+}
@@ -4,24 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/indiependente/shrtnr/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
 	uriFmt = "mongodb://%s:%s@%s:%s/%s"
+	// expiredRetention is how long expired shortened urls are kept before mongodb removes them,
+	// so that in the meantime they can be reported as expired rather than not found.
+	expiredRetention = 30 * 24 * time.Hour
+	// indexOptionsConflictCode is the code of the error creating an index that already exists with other options.
+	indexOptionsConflictCode = 85
 )
 
+// urlProjection leaves the sketch of the visitors out of the shortened urls read,
+// it can grow to a few hundred kilobytes and is only needed by Visitors.
+var urlProjection = bson.D{{Key: "visitors", Value: 0}}
+
 // mongoURLShortened is the model representation of the data for the mongo database.
 type mongoURLShortened struct {
-	ID   primitive.ObjectID `bson:"_id,omitempty"`
-	URL  string             `bson:"url"`
-	Slug string             `bson:"slug"`
-	Hits int                `bson:"hits"`
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	URL            string             `bson:"url"`
+	Slug           string             `bson:"slug"`
+	Hits           int                `bson:"hits"`
+	BotHits        int                `bson:"bot_hits,omitempty"`
+	ExpiresAt      *time.Time         `bson:"expires_at,omitempty"`
+	MaxHits        int                `bson:"max_hits,omitempty"`
+	PasswordHash   string             `bson:"password_hash,omitempty"`
+	RedirectStatus int                `bson:"redirect_status,omitempty"`
+	FoldedSlug     bool               `bson:"folded_slug,omitempty"`
 }
 
 // MongoDBStorer implements the Storer using a MongoDB store.
@@ -36,18 +55,40 @@ func NewMongoDBURLStorer(coll *mongo.Collection) MongoDBURLStorer {
 	}
 }
 
-// Add adds a shortened url to the mongodb repository.
+// EnsureIndexes creates the indexes needed by the MongoDBURLStorer, if missing.
+// The unique index on the slug is what makes Add safe against concurrent inserts of the same slug,
+// the TTL index on the expiration time removes expired shortened urls after a retention period.
 // Returns an error if any.
-func (m MongoDBURLStorer) Add(ctx context.Context, shortened models.URLShortened) error {
-	url, err := m.Get(ctx, shortened.Slug)
-	if err != nil && !errors.Is(err, ErrSlugNotFound) { // err can be ErrSlugNotFound
-		return fmt.Errorf("could not lookup: %w", err)
+func (m MongoDBURLStorer) EnsureIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "url", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(expiredRetention.Seconds())),
+		},
 	}
-	if url.Slug == shortened.Slug {
-		return fmt.Errorf("could not add: %w", ErrSlugAlreadyInUse)
+	_, err := m.urls.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		return fmt.Errorf("could not create indexes: %w", err)
 	}
-	_, err = m.urls.InsertOne(ctx, toMongo(shortened))
+	return nil
+}
+
+// Add adds a shortened url to the mongodb repository.
+// It relies on the unique index created by EnsureIndexes to detect slugs already in use.
+// Returns an error if any.
+func (m MongoDBURLStorer) Add(ctx context.Context, shortened models.URLShortened) error {
+	_, err := m.urls.InsertOne(ctx, toMongo(shortened))
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("could not add: %w", ErrSlugAlreadyInUse)
+		}
 		return fmt.Errorf("could not insert: %w", err)
 	}
 	return nil
@@ -57,7 +98,7 @@ func (m MongoDBURLStorer) Add(ctx context.Context, shortened models.URLShortened
 // Returns an error if any.
 func (m MongoDBURLStorer) Get(ctx context.Context, slug string) (models.URLShortened, error) {
 	var shortURL mongoURLShortened
-	err := m.urls.FindOne(ctx, bson.D{{Key: "slug", Value: slug}}).Decode(&shortURL)
+	err := m.urls.FindOne(ctx, bson.D{{Key: "slug", Value: slug}}, options.FindOne().SetProjection(urlProjection)).Decode(&shortURL)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return models.URLShortened{}, ErrSlugNotFound
@@ -71,7 +112,29 @@ func (m MongoDBURLStorer) Get(ctx context.Context, slug string) (models.URLShort
 // Returns an error if any.
 func (m MongoDBURLStorer) GetURL(ctx context.Context, url string) (models.URLShortened, error) {
 	var shortURL mongoURLShortened
-	err := m.urls.FindOne(ctx, bson.D{{Key: "url", Value: url}}).Decode(&shortURL)
+	err := m.urls.FindOne(ctx, bson.D{{Key: "url", Value: url}}, options.FindOne().SetProjection(urlProjection)).Decode(&shortURL)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.URLShortened{}, ErrURLNotFound
+		}
+		return models.URLShortened{}, fmt.Errorf("unexpected error: %w", err)
+	}
+	return toModel(shortURL), nil
+}
+
+// GetShareableURL gets the shareable shortened url of the url, the one without per link settings,
+// from the mongodb repository.
+// Returns an error if any.
+func (m MongoDBURLStorer) GetShareableURL(ctx context.Context, url string) (models.URLShortened, error) {
+	filter := bson.D{
+		{Key: "url", Value: url},
+		{Key: "expires_at", Value: nil}, // matches missing fields too
+		{Key: "max_hits", Value: bson.D{{Key: "$in", Value: bson.A{nil, 0}}}},
+		{Key: "password_hash", Value: bson.D{{Key: "$in", Value: bson.A{nil, ""}}}},
+		{Key: "redirect_status", Value: bson.D{{Key: "$in", Value: bson.A{nil, 0}}}},
+	}
+	var shortURL mongoURLShortened
+	err := m.urls.FindOne(ctx, filter, options.FindOne().SetProjection(urlProjection)).Decode(&shortURL)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return models.URLShortened{}, ErrURLNotFound
@@ -91,6 +154,23 @@ func (m MongoDBURLStorer) Update(ctx context.Context, newshort models.URLShorten
 			{Key: "lastModified", Value: true},
 		}},
 	}
+	// omitted optional fields must be removed from the stored document
+	unset := bson.D{}
+	if newshort.ExpiresAt == nil {
+		unset = append(unset, bson.E{Key: "expires_at", Value: ""})
+	}
+	if newshort.MaxHits == 0 {
+		unset = append(unset, bson.E{Key: "max_hits", Value: ""})
+	}
+	if newshort.PasswordHash == "" {
+		unset = append(unset, bson.E{Key: "password_hash", Value: ""})
+	}
+	if newshort.RedirectStatus == 0 {
+		unset = append(unset, bson.E{Key: "redirect_status", Value: ""})
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
 	result, err := m.urls.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("could not update: %w", err)
@@ -101,6 +181,152 @@ func (m MongoDBURLStorer) Update(ctx context.Context, newshort models.URLShorten
 	return nil
 }
 
+// IncrementHits atomically increments by delta the hit counter of the shortened url identified by the slug.
+// Returns an error if any.
+func (m MongoDBURLStorer) IncrementHits(ctx context.Context, slug string, delta int) error {
+	filter := bson.D{{Key: "slug", Value: slug}}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "hits", Value: delta},
+		}},
+		{Key: "$currentDate", Value: bson.D{
+			{Key: "lastModified", Value: true},
+		}},
+	}
+	result, err := m.urls.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("could not increment hits: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("could not increment hits: %w", ErrSlugNotFound)
+	}
+	return nil
+}
+
+// BulkIncrementHits atomically increments the hit counters of many shortened urls with a single round trip.
+// The deltas map slugs to their increment, slugs that could not be found are skipped.
+// Returns an error if any.
+func (m MongoDBURLStorer) BulkIncrementHits(ctx context.Context, deltas map[string]int) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, 0, len(deltas))
+	slugs := make([]string, 0, len(deltas))
+	for slug, delta := range deltas {
+		slugs = append(slugs, slug)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "slug", Value: slug}}).
+			SetUpdate(bson.D{
+				{Key: "$inc", Value: bson.D{
+					{Key: "hits", Value: delta},
+				}},
+				{Key: "$currentDate", Value: bson.D{
+					{Key: "lastModified", Value: true},
+				}},
+			}))
+	}
+	_, err := m.urls.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("could not increment hits: %w", partialWriteError(err, slugs))
+	}
+	return nil
+}
+
+// BulkIncrementBotHits atomically increments the bot hit counters of many shortened urls with a single round trip.
+// The deltas map slugs to their increment, slugs that could not be found are skipped.
+// Returns an error if any.
+func (m MongoDBURLStorer) BulkIncrementBotHits(ctx context.Context, deltas map[string]int) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, 0, len(deltas))
+	slugs := make([]string, 0, len(deltas))
+	for slug, delta := range deltas {
+		slugs = append(slugs, slug)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "slug", Value: slug}}).
+			SetUpdate(bson.D{
+				{Key: "$inc", Value: bson.D{
+					{Key: "bot_hits", Value: delta},
+				}},
+			}))
+	}
+	_, err := m.urls.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("could not increment bot hits: %w", partialWriteError(err, slugs))
+	}
+	return nil
+}
+
+// partialWriteError returns a PartialWriteError listing the keys of the writes that failed,
+// if the unordered bulk write failed for some of them only.
+// Other errors, e.g. network ones, are returned as they are since it is not known which writes were applied.
+func partialWriteError(err error, keys []string) error {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return err
+	}
+	failed := make([]string, 0, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Index < 0 || writeErr.Index >= len(keys) {
+			return err
+		}
+		failed = append(failed, keys[writeErr.Index])
+	}
+	return &PartialWriteError{Failed: failed, Err: err}
+}
+
+// duplicatesOnly reports whether every write of the unordered bulk write that failed did so because of a duplicate key,
+// meaning that what was written is already stored.
+func duplicatesOnly(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr.WriteError) {
+			return false
+		}
+	}
+	return true
+}
+
+// ConsumeHit atomically increments by one the hit counter of the shortened url identified by the slug,
+// unless it already reached its max hits.
+// Returns the updated shortened url or an error if any.
+func (m MongoDBURLStorer) ConsumeHit(ctx context.Context, slug string) (models.URLShortened, error) {
+	filter := bson.D{
+		{Key: "slug", Value: slug},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "max_hits", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{"$hits", "$max_hits"}}}}},
+		}},
+	}
+	update := bson.D{
+		{Key: "$inc", Value: bson.D{
+			{Key: "hits", Value: 1},
+		}},
+		{Key: "$currentDate", Value: bson.D{
+			{Key: "lastModified", Value: true},
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(urlProjection)
+	var shortURL mongoURLShortened
+	err := m.urls.FindOneAndUpdate(ctx, filter, update, opts).Decode(&shortURL)
+	if err == mongo.ErrNoDocuments {
+		// tell apart a missing slug from an exhausted one
+		_, err = m.Get(ctx, slug)
+		if err != nil {
+			return models.URLShortened{}, fmt.Errorf("could not consume hit: %w", err)
+		}
+		return models.URLShortened{}, fmt.Errorf("could not consume hit: %w", ErrHitLimitReached)
+	}
+	if err != nil {
+		return models.URLShortened{}, fmt.Errorf("could not consume hit: %w", err)
+	}
+	return toModel(shortURL), nil
+}
+
 // Delete deletes a shortened url from the mongodb repository.
 // Returns an error if any.
 func (m MongoDBURLStorer) Delete(ctx context.Context, slug string) error {
@@ -114,9 +340,455 @@ func (m MongoDBURLStorer) Delete(ctx context.Context, slug string) error {
 	return nil
 }
 
+// MergeVisitors merges the sketches into the ones of the visitors of many shortened urls with a single round trip,
+// raising each register to the highest rank with $max so that concurrent merges never lose a visitor.
+// The sketches map slugs to the visitors to merge, slugs that could not be found are skipped.
+// Returns an error if any.
+func (m MongoDBURLStorer) MergeVisitors(ctx context.Context, sketches map[string]models.Sketch) error {
+	writes := make([]mongo.WriteModel, 0, len(sketches))
+	for slug, sketch := range sketches {
+		if len(sketch) == 0 {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "slug", Value: slug}}).
+			SetUpdate(sketchUpdate("visitors", sketch)))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err := m.urls.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("could not merge visitors: %w", err)
+	}
+	return nil
+}
+
+// Visitors returns the sketch of the visitors of the shortened url identified by the slug.
+// Returns an error if any.
+func (m MongoDBURLStorer) Visitors(ctx context.Context, slug string) (models.Sketch, error) {
+	var doc struct {
+		Visitors map[string]int32 `bson:"visitors"`
+	}
+	opts := options.FindOne().SetProjection(bson.D{{Key: "visitors", Value: 1}})
+	err := m.urls.FindOne(ctx, bson.D{{Key: "slug", Value: slug}}, opts).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("could not get visitors: %w", ErrSlugNotFound)
+		}
+		return nil, fmt.Errorf("could not get visitors: %w", err)
+	}
+	return toModelSketch(doc.Visitors), nil
+}
+
+// MongoDBCounter implements the Counter using a MongoDB collection holding a document per counter.
+type MongoDBCounter struct {
+	counters *mongo.Collection
+}
+
+// mongoCounter is the model representation of a counter for the mongo database.
+type mongoCounter struct {
+	Name string `bson:"_id"`
+	Seq  uint64 `bson:"seq"`
+}
+
+// NewMongoDBCounter returns a new instance of a MongoDBCounter.
+func NewMongoDBCounter(coll *mongo.Collection) MongoDBCounter {
+	return MongoDBCounter{
+		counters: coll,
+	}
+}
+
+// Next atomically increments the counter identified by the name, creating it if missing, and returns its new value.
+// Counters start from 1.
+// Returns an error if any.
+func (m MongoDBCounter) Next(ctx context.Context, name string) (uint64, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	filter := bson.D{{Key: "_id", Value: name}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: int64(1)}}}}
+	var counter mongoCounter
+	err := m.counters.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent upsert created the counter first, it can be incremented now
+		err = m.counters.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not increment counter: %w", err)
+	}
+	return counter.Seq, nil
+}
+
+// MongoDBClickStorer implements the ClickStorer using a MongoDB collection holding a document per click,
+// and another one holding a document per daily sketch of the visitors of a slug.
+// Clicks and daily sketches older than the retention, if any, are removed by mongodb.
+type MongoDBClickStorer struct {
+	clicks    *mongo.Collection
+	visitors  *mongo.Collection
+	retention time.Duration
+}
+
+// mongoClick is the model representation of a click for the mongo database.
+type mongoClick struct {
+	// ID is the one of the click, or the ObjectID generated by mongodb for the clicks without one.
+	ID             interface{} `bson:"_id,omitempty"`
+	Slug           string      `bson:"slug"`
+	Time           time.Time   `bson:"time"`
+	Referrer       string      `bson:"referrer,omitempty"`
+	UserAgent      string      `bson:"user_agent,omitempty"`
+	IPHash         string      `bson:"ip_hash,omitempty"`
+	AcceptLanguage string      `bson:"accept_language,omitempty"`
+	RequestID      string      `bson:"request_id,omitempty"`
+	Browser        string      `bson:"browser,omitempty"`
+	Country        string      `bson:"country,omitempty"`
+	Bot            bool        `bson:"bot,omitempty"`
+}
+
+// mongoDailySketch is the model representation of a daily sketch of the visitors for the mongo database.
+type mongoDailySketch struct {
+	Slug      string           `bson:"slug"`
+	Day       time.Time        `bson:"day"`
+	Registers map[string]int32 `bson:"registers"`
+}
+
+// NewMongoDBClickStorer returns a new instance of a MongoDBClickStorer,
+// keeping clicks for the retention, or forever if not positive.
+func NewMongoDBClickStorer(clicks, visitors *mongo.Collection, retention time.Duration) MongoDBClickStorer {
+	return MongoDBClickStorer{
+		clicks:    clicks,
+		visitors:  visitors,
+		retention: retention,
+	}
+}
+
+// EnsureIndexes creates the indexes needed by the MongoDBClickStorer, if missing,
+// to select the clicks of a slug or of all of them by time,
+// and to find the daily sketch of the visitors of a slug, which must be unique for merges to upsert it safely.
+// With a retention the indexes on time are TTL ones, changed to the retention if they already exist with another one.
+// Returns an error if any.
+func (m MongoDBClickStorer) EnsureIndexes(ctx context.Context) error {
+	_, err := m.clicks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "slug", Value: 1}, {Key: "time", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("could not create indexes: %w", err)
+	}
+	err = m.ensureTimeIndex(ctx, m.clicks, "time")
+	if err != nil {
+		return fmt.Errorf("could not create indexes: %w", err)
+	}
+	_, err = m.visitors.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}, {Key: "day", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create visitors indexes: %w", err)
+	}
+	err = m.ensureTimeIndex(ctx, m.visitors, "day")
+	if err != nil {
+		return fmt.Errorf("could not create visitors indexes: %w", err)
+	}
+	return nil
+}
+
+// ensureTimeIndex creates the index on the time field of the collection, expiring the documents after the retention if any.
+// An existing index with another retention, or none, is changed to the retention,
+// but an existing one is never changed to keep documents forever, it has to be dropped instead.
+// Returns an error if any.
+func (m MongoDBClickStorer) ensureTimeIndex(ctx context.Context, coll *mongo.Collection, field string) error {
+	index := mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}}
+	if m.retention <= 0 {
+		_, err := coll.Indexes().CreateOne(ctx, index)
+		if isIndexOptionsConflict(err) {
+			return nil // the index already expires documents
+		}
+		return err
+	}
+	seconds := int64(m.retention / time.Second)
+	if seconds > math.MaxInt32 {
+		return fmt.Errorf("retention of %s too long", m.retention)
+	}
+	index.Options = options.Index().SetExpireAfterSeconds(int32(seconds))
+	_, err := coll.Indexes().CreateOne(ctx, index)
+	if isIndexOptionsConflict(err) {
+		err = coll.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "index", Value: bson.D{{Key: "keyPattern", Value: index.Keys}, {Key: "expireAfterSeconds", Value: seconds}}},
+		}).Err()
+	}
+	return err
+}
+
+// isIndexOptionsConflict reports whether the index could not be created because it already exists with other options.
+func isIndexOptionsConflict(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == indexOptionsConflictCode
+}
+
+// AddClicks adds the clicks to the mongodb repository in a single unordered bulk insert,
+// so that a failing click does not prevent the others from being written.
+// Clicks are stored with their ID as _id, so the ones already stored fail with a duplicate key error, which is ignored.
+// Returns an error if any.
+func (m MongoDBClickStorer) AddClicks(ctx context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(clicks))
+	for _, click := range clicks {
+		docs = append(docs, toMongoClick(click))
+	}
+	_, err := m.clicks.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !duplicatesOnly(err) {
+		return fmt.Errorf("could not add clicks: %w", err)
+	}
+	return nil
+}
+
+// IterateClicks calls fn with the clicks selected by the query, in chronological order,
+// reading them from a cursor so that they are never loaded all together.
+// Returns an error if any.
+func (m MongoDBClickStorer) IterateClicks(ctx context.Context, query ClickQuery, fn func(models.Click) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := m.clicks.Find(ctx, clickFilter(query), opts)
+	if err != nil {
+		return fmt.Errorf("could not find clicks: %w", err)
+	}
+	defer cursor.Close(ctx) // nolint: errcheck
+	for cursor.Next(ctx) {
+		var click mongoClick
+		err = cursor.Decode(&click)
+		if err != nil {
+			return fmt.Errorf("could not decode click: %w", err)
+		}
+		if err = fn(toModelClick(click)); err != nil {
+			return err
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return fmt.Errorf("could not iterate clicks: %w", err)
+	}
+	return nil
+}
+
+// mongoClickStats is the result of the aggregation of the clicks into statistics, a field per facet.
+type mongoClickStats struct {
+	Total []struct {
+		Count int `bson:"count"`
+	} `bson:"total"`
+	Bots []struct {
+		Count int `bson:"count"`
+	} `bson:"bots"`
+	Buckets []struct {
+		Start time.Time `bson:"_id"`
+		Count int       `bson:"count"`
+	} `bson:"buckets"`
+	Referrers  []mongoTally `bson:"referrers"`
+	UserAgents []mongoTally `bson:"user_agents"`
+	Browsers   []mongoTally `bson:"browsers"`
+	Countries  []mongoTally `bson:"countries"`
+}
+
+// mongoTally is the number of clicks having the value.
+type mongoTally struct {
+	Value string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+// humanClicks is the stage leaving the clicks made by bots out of a facet.
+var humanClicks = bson.D{{Key: "$match", Value: bson.D{{Key: "bot", Value: bson.D{{Key: "$ne", Value: true}}}}}}
+
+// ClickStats aggregates the clicks selected by the query into statistics, in a single pipeline with a facet per statistic.
+// The clicks made by bots are only counted, every other facet leaves them out.
+// Buckets are computed arithmetically from models.IntervalOrigin, so that they need no date operator of recent MongoDB versions.
+// Returns an error if any.
+func (m MongoDBClickStorer) ClickStats(ctx context.Context, query ClickQuery, interval models.StatsInterval, top int) (models.ClickStats, error) {
+	width := interval.Duration().Milliseconds()
+	bucketStart := bson.D{{Key: "$subtract", Value: bson.A{
+		"$time",
+		bson.D{{Key: "$mod", Value: bson.A{
+			bson.D{{Key: "$subtract", Value: bson.A{"$time", models.IntervalOrigin}}},
+			width,
+		}}},
+	}}}
+	facets := bson.D{
+		{Key: "total", Value: bson.A{humanClicks, bson.D{{Key: "$count", Value: "count"}}}},
+		{Key: "bots", Value: bson.A{
+			bson.D{{Key: "$match", Value: bson.D{{Key: "bot", Value: true}}}},
+			bson.D{{Key: "$count", Value: "count"}},
+		}},
+		{Key: "buckets", Value: bson.A{
+			humanClicks,
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bucketStart},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		}},
+	}
+	if top > 0 {
+		// $limit rejects values that are not positive, so the tallies are left out rather than failing the whole aggregation
+		facets = append(facets,
+			bson.E{Key: "referrers", Value: topPipeline("referrer", top)},
+			bson.E{Key: "user_agents", Value: topPipeline("user_agent", top)},
+			bson.E{Key: "browsers", Value: topPipeline("browser", top)},
+			bson.E{Key: "countries", Value: topPipeline("country", top)},
+		)
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: clickFilter(query)}},
+		{{Key: "$facet", Value: facets}},
+	}
+	cursor, err := m.clicks.Aggregate(ctx, pipeline)
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("could not aggregate clicks: %w", err)
+	}
+	defer cursor.Close(ctx) // nolint: errcheck
+	var results []mongoClickStats
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("could not decode click stats: %w", err)
+	}
+	stats := models.ClickStats{}
+	if len(results) == 0 { // a facet stage always returns a document, this is just defensive
+		return stats, nil
+	}
+	result := results[0]
+	if len(result.Total) > 0 {
+		stats.Total = result.Total[0].Count
+	}
+	if len(result.Bots) > 0 {
+		stats.Bots = result.Bots[0].Count
+	}
+	for _, bucket := range result.Buckets {
+		stats.Buckets = append(stats.Buckets, models.ClickBucket{Start: bucket.Start.UTC(), Count: bucket.Count})
+	}
+	stats.Referrers = toModelTallies(result.Referrers)
+	stats.UserAgents = toModelTallies(result.UserAgents)
+	stats.Browsers = toModelTallies(result.Browsers)
+	stats.Countries = toModelTallies(result.Countries)
+	return stats, nil
+}
+
+// topPipeline returns the facet counting the clicks made by people per value of the field, keeping the top most frequent ones.
+func topPipeline(field string, top int) bson.A {
+	return bson.A{
+		humanClicks,
+		bson.D{{Key: "$match", Value: bson.D{{Key: field, Value: bson.D{{Key: "$nin", Value: bson.A{nil, ""}}}}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$" + field},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: top}},
+	}
+}
+
+// MergeDailyVisitors merges the sketches into the stored ones of the same slug and day with a single round trip,
+// creating the missing ones and raising each register to the highest rank with $max so that concurrent merges never lose a visitor.
+// Returns an error if any.
+func (m MongoDBClickStorer) MergeDailyVisitors(ctx context.Context, sketches []models.DailySketch) error {
+	writes := make([]mongo.WriteModel, 0, len(sketches))
+	for _, sketch := range sketches {
+		if len(sketch.Sketch) == 0 {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "slug", Value: sketch.Slug}, {Key: "day", Value: sketch.Day}}).
+			SetUpdate(sketchUpdate("registers", sketch.Sketch)).
+			SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+	_, err := m.visitors.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("could not merge daily visitors: %w", err)
+	}
+	return nil
+}
+
+// DeleteClicks deletes the clicks and the daily sketches of the visitors of the slug from the mongodb repository.
+// Returns an error if any.
+func (m MongoDBClickStorer) DeleteClicks(ctx context.Context, slug string) error {
+	filter := bson.D{{Key: "slug", Value: slug}}
+	_, err := m.clicks.DeleteMany(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("could not delete clicks: %w", err)
+	}
+	_, err = m.visitors.DeleteMany(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("could not delete daily visitors: %w", err)
+	}
+	return nil
+}
+
+// DailyVisitors returns the daily sketches of the visitors selected by the query, in chronological order.
+// Returns an error if any.
+func (m MongoDBClickStorer) DailyVisitors(ctx context.Context, query ClickQuery) ([]models.DailySketch, error) {
+	filter := clickFilter(query)
+	for i := range filter {
+		if filter[i].Key == "time" {
+			filter[i].Key = "day"
+		}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "day", Value: 1}, {Key: "slug", Value: 1}})
+	cursor, err := m.visitors.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not find daily visitors: %w", err)
+	}
+	var docs []mongoDailySketch
+	err = cursor.All(ctx, &docs)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode daily visitors: %w", err)
+	}
+	sketches := make([]models.DailySketch, 0, len(docs))
+	for _, doc := range docs {
+		sketches = append(sketches, models.DailySketch{
+			Slug:   doc.Slug,
+			Day:    doc.Day.UTC(),
+			Sketch: toModelSketch(doc.Registers),
+		})
+	}
+	return sketches, nil
+}
+
+// sketchUpdate returns the mongodb update merging the sketch into the one stored in the field,
+// a document mapping register indexes to their rank.
+func sketchUpdate(field string, sketch models.Sketch) bson.D {
+	registers := make(bson.D, 0, len(sketch))
+	for index, rank := range sketch {
+		registers = append(registers, bson.E{Key: field + "." + strconv.Itoa(index), Value: int32(rank)})
+	}
+	return bson.D{{Key: "$max", Value: registers}}
+}
+
+// clickFilter returns the mongodb filter selecting the clicks of the query.
+func clickFilter(query ClickQuery) bson.D {
+	filter := bson.D{}
+	if query.Slug != "" {
+		filter = append(filter, bson.E{Key: "slug", Value: query.Slug})
+	}
+	timeRange := bson.D{}
+	if !query.From.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$gte", Value: query.From})
+	}
+	if !query.To.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$lt", Value: query.To})
+	}
+	if len(timeRange) > 0 {
+		filter = append(filter, bson.E{Key: "time", Value: timeRange})
+	}
+	return filter
+}
+
 // Configs MongoDB configuration
 type Configs struct {
 	User, Pass, Host, Port, DB, Collection string
+	// CountersCollection is the collection holding the counters, it defaults to counters.
+	CountersCollection string
+	// ClicksCollection is the collection holding the clicks, it defaults to clicks.
+	ClicksCollection string
+	// VisitorsCollection is the collection holding the daily sketches of the visitors, it defaults to visitors.
+	VisitorsCollection string
 }
 
 // URI returns the URI string.
@@ -132,21 +804,103 @@ func BuildMongoConfigs() Configs {
 	port := os.Getenv("MONGODB_PORT")
 	db := os.Getenv("MONGODB_DB")
 	coll := os.Getenv("MONGODB_COLLECTION")
-	return Configs{User: user, Pass: pass, Host: host, Port: port, DB: db, Collection: coll}
+	counters := os.Getenv("MONGODB_COUNTERS_COLLECTION")
+	if counters == "" {
+		counters = "counters"
+	}
+	clicks := os.Getenv("MONGODB_CLICKS_COLLECTION")
+	if clicks == "" {
+		clicks = "clicks"
+	}
+	visitors := os.Getenv("MONGODB_VISITORS_COLLECTION")
+	if visitors == "" {
+		visitors = "visitors"
+	}
+	return Configs{User: user, Pass: pass, Host: host, Port: port, DB: db, Collection: coll, CountersCollection: counters,
+		ClicksCollection: clicks, VisitorsCollection: visitors}
 }
 
 func toMongo(u models.URLShortened) mongoURLShortened {
 	return mongoURLShortened{
-		URL:  u.URL,
-		Slug: u.Slug,
-		Hits: u.Hits,
+		URL:            u.URL,
+		Slug:           u.Slug,
+		Hits:           u.Hits,
+		BotHits:        u.BotHits,
+		ExpiresAt:      u.ExpiresAt,
+		MaxHits:        u.MaxHits,
+		PasswordHash:   u.PasswordHash,
+		RedirectStatus: u.RedirectStatus,
+		FoldedSlug:     u.FoldedSlug,
 	}
 }
 
 func toModel(mu mongoURLShortened) models.URLShortened {
 	return models.URLShortened{
-		URL:  mu.URL,
-		Slug: mu.Slug,
-		Hits: mu.Hits,
+		URL:            mu.URL,
+		Slug:           mu.Slug,
+		Hits:           mu.Hits,
+		BotHits:        mu.BotHits,
+		ExpiresAt:      mu.ExpiresAt,
+		MaxHits:        mu.MaxHits,
+		PasswordHash:   mu.PasswordHash,
+		RedirectStatus: mu.RedirectStatus,
+		FoldedSlug:     mu.FoldedSlug,
+	}
+}
+
+func toMongoClick(c models.Click) mongoClick {
+	var id interface{}
+	if c.ID != "" {
+		id = c.ID
+	}
+	return mongoClick{
+		ID:             id,
+		Slug:           c.Slug,
+		Time:           c.Time,
+		Referrer:       c.Referrer,
+		UserAgent:      c.UserAgent,
+		IPHash:         c.IPHash,
+		AcceptLanguage: c.AcceptLanguage,
+		RequestID:      c.RequestID,
+		Browser:        c.Browser,
+		Country:        c.Country,
+		Bot:            c.Bot,
+	}
+}
+
+func toModelClick(mc mongoClick) models.Click {
+	id, _ := mc.ID.(string) // generated ObjectIDs do not identify clicks
+	return models.Click{
+		ID:             id,
+		Slug:           mc.Slug,
+		Time:           mc.Time,
+		Referrer:       mc.Referrer,
+		UserAgent:      mc.UserAgent,
+		IPHash:         mc.IPHash,
+		AcceptLanguage: mc.AcceptLanguage,
+		RequestID:      mc.RequestID,
+		Browser:        mc.Browser,
+		Country:        mc.Country,
+		Bot:            mc.Bot,
+	}
+}
+
+func toModelTallies(mts []mongoTally) []models.Tally {
+	tallies := make([]models.Tally, 0, len(mts))
+	for _, mt := range mts {
+		tallies = append(tallies, models.Tally{Value: mt.Value, Count: mt.Count})
+	}
+	return tallies
+}
+
+func toModelSketch(registers map[string]int32) models.Sketch {
+	sketch := models.NewSketch()
+	for key, rank := range registers {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= models.SketchRegisters {
+			continue
+		}
+		sketch.Set(index, uint8(rank))
 	}
+	return sketch
 }
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestDuplicatesOnly(t *testing.T) {
+	t.Parallel()
+
+	writeErr := func(index, code int) mongo.BulkWriteError {
+		return mongo.BulkWriteError{WriteError: mongo.WriteError{Index: index, Code: code, Message: "bad"}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "Happy Path - duplicate keys only",
+			err:  mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{writeErr(0, 11000), writeErr(2, 11000)}},
+			want: true,
+		},
+		{
+			name: "Sad Path - other write errors",
+			err:  mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{writeErr(0, 11000), writeErr(1, 2)}},
+			want: false,
+		},
+		{
+			name: "Sad Path - write concern error",
+			err: mongo.BulkWriteException{
+				WriteErrors:       []mongo.BulkWriteError{writeErr(0, 11000)},
+				WriteConcernError: &mongo.WriteConcernError{Code: 64, Message: "timeout"},
+			},
+			want: false,
+		},
+		{
+			name: "Sad Path - not a bulk write error",
+			err:  errors.New("connection reset"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, duplicatesOnly(fmt.Errorf("could not write: %w", tt.err)))
+		})
+	}
+}
+
+func TestPartialWriteError(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"pizza", "pasta", "risotto"}
+	writeErr := func(index int) mongo.BulkWriteError {
+		return mongo.BulkWriteError{WriteError: mongo.WriteError{Index: index, Code: 2, Message: "bad"}}
+	}
+	tests := []struct {
+		name       string
+		err        error
+		wantFailed []string
+	}{
+		{
+			name:       "Happy Path - some writes failed",
+			err:        mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{writeErr(0), writeErr(2)}},
+			wantFailed: []string{"pizza", "risotto"},
+		},
+		{
+			name: "Sad Path - write concern error",
+			err: mongo.BulkWriteException{
+				WriteErrors:       []mongo.BulkWriteError{writeErr(0)},
+				WriteConcernError: &mongo.WriteConcernError{Code: 64, Message: "timeout"},
+			},
+		},
+		{
+			name: "Sad Path - index out of range",
+			err:  mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{writeErr(3)}},
+		},
+		{
+			name: "Sad Path - not a bulk write error",
+			err:  errors.New("connection reset"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("could not write: %w", partialWriteError(tt.err, keys))
+			var partial *PartialWriteError
+			if tt.wantFailed == nil {
+				require.False(t, errors.As(err, &partial), "it is not known which writes were applied")
+				require.True(t, errors.Is(err, tt.err) || errors.As(err, new(mongo.BulkWriteException)))
+				return
+			}
+			require.True(t, errors.As(err, &partial))
+			require.Equal(t, tt.wantFailed, partial.Failed)
+			require.True(t, errors.As(err, new(mongo.BulkWriteException)), "the bulk write error is wrapped")
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
@@ -76,16 +77,7 @@ func TestMongoDBURLStorer_Add(t *testing.T) {
 			coll := db.Collection(fmt.Sprintf("urls_test_add_%d%d", time.Now().UnixNano(), rand.Int()))
 			defer coll.Drop(ctx) // nolint: errcheck
 			// add indexes
-			models := []mongo.IndexModel{
-				{
-					Keys: bson.D{{Key: "slug", Value: 1}},
-				},
-				{
-					Keys: bson.D{{Key: "url", Value: 1}},
-				},
-			}
-			opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
-			_, err = coll.Indexes().CreateMany(ctx, models, opts)
+			err = NewMongoDBURLStorer(coll).EnsureIndexes(ctx)
 			require.NoError(t, err)
 			defer coll.Indexes().DropAll(ctx) // nolint: errcheck
 			// run additional collection setup func
@@ -156,16 +148,7 @@ func TestMongoDBURLStorer_Delete(t *testing.T) {
 			coll := db.Collection(fmt.Sprintf("urls_test_delete_%d%d", time.Now().UnixNano(), rand.Int()))
 			defer coll.Drop(ctx) // nolint: errcheck
 			// add indexes
-			models := []mongo.IndexModel{
-				{
-					Keys: bson.D{{Key: "slug", Value: 1}},
-				},
-				{
-					Keys: bson.D{{Key: "url", Value: 1}},
-				},
-			}
-			opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
-			_, err = coll.Indexes().CreateMany(ctx, models, opts)
+			err = NewMongoDBURLStorer(coll).EnsureIndexes(ctx)
 			require.NoError(t, err)
 			defer coll.Indexes().DropAll(ctx) // nolint: errcheck
 			// run additional collection setup func
@@ -234,16 +217,7 @@ func TestMongoDBURLStorer_Get(t *testing.T) {
 			coll := db.Collection(fmt.Sprintf("urls_test_get_%d%d", time.Now().UnixNano(), rand.Int()))
 			defer coll.Drop(ctx) // nolint: errcheck
 			// add indexes
-			models := []mongo.IndexModel{
-				{
-					Keys: bson.D{{Key: "slug", Value: 1}},
-				},
-				{
-					Keys: bson.D{{Key: "url", Value: 1}},
-				},
-			}
-			opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
-			_, err = coll.Indexes().CreateMany(ctx, models, opts)
+			err = NewMongoDBURLStorer(coll).EnsureIndexes(ctx)
 			require.NoError(t, err)
 			defer coll.Indexes().DropAll(ctx) // nolint: errcheck
 			// run additional collection setup func
@@ -315,16 +289,7 @@ func TestMongoDBURLStorer_Update(t *testing.T) {
 			coll := db.Collection(fmt.Sprintf("urls_test_update_%d%d", time.Now().UnixNano(), rand.Int()))
 			defer coll.Drop(ctx) // nolint: errcheck
 			// add indexes
-			models := []mongo.IndexModel{
-				{
-					Keys: bson.D{{Key: "slug", Value: 1}},
-				},
-				{
-					Keys: bson.D{{Key: "url", Value: 1}},
-				},
-			}
-			opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
-			_, err = coll.Indexes().CreateMany(ctx, models, opts)
+			err = NewMongoDBURLStorer(coll).EnsureIndexes(ctx)
 			require.NoError(t, err)
 			defer coll.Indexes().DropAll(ctx) // nolint: errcheck
 			// run additional collection setup func
@@ -395,16 +360,7 @@ func TestMongoDBURLStorer_GetURL(t *testing.T) {
 			coll := db.Collection(fmt.Sprintf("urls_test_geturl_%d%d", time.Now().UnixNano(), rand.Int()))
 			defer coll.Drop(ctx) // nolint: errcheck
 			// add indexes
-			models := []mongo.IndexModel{
-				{
-					Keys: bson.D{{Key: "slug", Value: 1}},
-				},
-				{
-					Keys: bson.D{{Key: "url", Value: 1}},
-				},
-			}
-			opts := options.CreateIndexes().SetMaxTime(2 * time.Second)
-			_, err = coll.Indexes().CreateMany(ctx, models, opts)
+			err = NewMongoDBURLStorer(coll).EnsureIndexes(ctx)
 			require.NoError(t, err)
 			defer coll.Indexes().DropAll(ctx) // nolint: errcheck
 			// run additional collection setup func
@@ -420,3 +376,54 @@ func TestMongoDBURLStorer_GetURL(t *testing.T) {
 		})
 	}
 }
+
+func TestMongoDBURLStorer_AddConcurrent(t *testing.T) {
+	t.Parallel()
+	const inserts = 100
+	// *** START DB SETUP ***
+	rand.Seed(time.Now().UnixNano())
+	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	err = client.Connect(ctx)
+	require.NoError(t, err)
+	defer client.Disconnect(ctx) // nolint: errcheck
+	db := client.Database("shrtnr")
+	// create collection
+	coll := db.Collection(fmt.Sprintf("urls_test_add_concurrent_%d%d", time.Now().UnixNano(), rand.Int()))
+	defer coll.Drop(ctx) // nolint: errcheck
+	// add indexes
+	err = NewMongoDBURLStorer(coll).EnsureIndexes(ctx)
+	require.NoError(t, err)
+	defer coll.Indexes().DropAll(ctx) // nolint: errcheck
+	// *** END DB SETUP ***
+	// create store and race N inserts of the same slug
+	store := NewMongoDBURLStorer(coll)
+	errs := make(chan error, inserts)
+	var wg sync.WaitGroup
+	for i := 0; i < inserts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.Add(ctx, models.URLShortened{
+				URL:  fmt.Sprintf("https://shrtnr.dev/%d", i),
+				Slug: "aeiou",
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	added := 0
+	for err := range errs {
+		if err == nil {
+			added++
+			continue
+		}
+		require.True(t, errors.Is(err, ErrSlugAlreadyInUse))
+	}
+	require.Equal(t, 1, added)
+	count, err := coll.CountDocuments(ctx, bson.D{{Key: "slug", Value: "aeiou"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+}
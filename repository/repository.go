@@ -4,6 +4,8 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/indiependente/shrtnr/models"
 )
@@ -15,6 +17,8 @@ const (
 	ErrSlugNotFound Error = `slug not found`
 	// ErrURLNotFound is returned when trying to retrieve a URL that could not be found in the repository.
 	ErrURLNotFound Error = `url not found`
+	// ErrHitLimitReached is returned when trying to consume a hit of a shortened url that reached its max hits.
+	ErrHitLimitReached Error = `hit limit reached`
 )
 
 // Error represents an error returned by the repository.
@@ -25,11 +29,82 @@ func (e Error) Error() string {
 	return string(e)
 }
 
+// PartialWriteError is returned by the bulk writes that failed for some of their items only, the other ones being written,
+// so that only the failed ones are retried.
+type PartialWriteError struct {
+	// Failed are the keys of the items that were not written, e.g. their slugs.
+	Failed []string
+	Err    error
+}
+
+// Error returns the string representation of the error.
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("%d items not written: %v", len(e.Failed), e.Err)
+}
+
+// Unwrap returns the error of the failed writes.
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
 // Storer defines the behaviour of a component capable of storing shortened urls, retrieving and deleting existing ones.
+// The sketch of the visitors of a shortened url is kept with it, but only merged into and read on its own,
+// so that redirects never carry it around.
 type Storer interface {
 	Add(ctx context.Context, shortened models.URLShortened) error
 	Get(ctx context.Context, slug string) (models.URLShortened, error)
 	GetURL(ctx context.Context, url string) (models.URLShortened, error)
+	GetShareableURL(ctx context.Context, url string) (models.URLShortened, error)
 	Update(ctx context.Context, newshortened models.URLShortened) error
+	IncrementHits(ctx context.Context, slug string, delta int) error
+	BulkIncrementHits(ctx context.Context, deltas map[string]int) error
+	BulkIncrementBotHits(ctx context.Context, deltas map[string]int) error
+	ConsumeHit(ctx context.Context, slug string) (models.URLShortened, error)
 	Delete(ctx context.Context, slug string) error
+	MergeVisitors(ctx context.Context, sketches map[string]models.Sketch) error
+	Visitors(ctx context.Context, slug string) (models.Sketch, error)
+}
+
+// Counter defines the behaviour of a component capable of handing out monotonically increasing numbers,
+// each of them exactly once, even across restarts if persistent.
+type Counter interface {
+	Next(ctx context.Context, name string) (uint64, error)
+}
+
+// ClickQuery selects the clicks of a slug, or of all of them if empty, that happened in [From, To).
+// Zero times leave the range unbounded on their side.
+type ClickQuery struct {
+	Slug     string
+	From, To time.Time
+}
+
+// ClickStorer defines the behaviour of a component capable of storing clicks on shortened urls, iterating over them
+// and aggregating them into statistics.
+// Clicks having the ID of one already stored are skipped, so that failed writes can be retried as a whole.
+// Clicks are iterated in chronological order, one at a time, so that they never need to fit in memory all together,
+// until fn returns an error, which is then returned as is.
+// Statistics have the buckets with clicks only, and the top most frequent values of each tally among the clicks having one,
+// ties broken by value, none if top is not positive. Clicks made by bots are only counted, they are left out of all the other statistics.
+// Daily sketches of the visitors are merged into the stored ones, and returned in chronological order for the days
+// starting in the range of the query.
+// Clicks and daily sketches of a slug are deleted along with its shortened url, so that a new one never inherits them.
+type ClickStorer interface {
+	AddClicks(ctx context.Context, clicks []models.Click) error
+	IterateClicks(ctx context.Context, query ClickQuery, fn func(models.Click) error) error
+	ClickStats(ctx context.Context, query ClickQuery, interval models.StatsInterval, top int) (models.ClickStats, error)
+	MergeDailyVisitors(ctx context.Context, sketches []models.DailySketch) error
+	DailyVisitors(ctx context.Context, query ClickQuery) ([]models.DailySketch, error)
+	DeleteClicks(ctx context.Context, slug string) error
+}
+
+// Match reports whether the click is selected by the query.
+func (q ClickQuery) Match(click models.Click) bool {
+	return q.matches(click.Slug, click.Time)
+}
+
+// matches reports whether anything of the slug happening at t is selected by the query.
+func (q ClickQuery) matches(slug string, t time.Time) bool {
+	return (q.Slug == "" || slug == q.Slug) &&
+		(q.From.IsZero() || !t.Before(q.From)) &&
+		(q.To.IsZero() || t.Before(q.To))
 }
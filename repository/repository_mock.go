@@ -72,6 +72,19 @@ func (mr *MockStorerMockRecorder) GetURL(ctx, url interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURL", reflect.TypeOf((*MockStorer)(nil).GetURL), ctx, url)
 }
 
+// GetShareableURL mocks base method
+func (m *MockStorer) GetShareableURL(ctx context.Context, url string) (models.URLShortened, error) {
+	ret := m.ctrl.Call(m, "GetShareableURL", ctx, url)
+	ret0, _ := ret[0].(models.URLShortened)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShareableURL indicates an expected call of GetShareableURL
+func (mr *MockStorerMockRecorder) GetShareableURL(ctx, url interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShareableURL", reflect.TypeOf((*MockStorer)(nil).GetShareableURL), ctx, url)
+}
+
 // Update mocks base method
 func (m *MockStorer) Update(ctx context.Context, newshortened models.URLShortened) error {
 	ret := m.ctrl.Call(m, "Update", ctx, newshortened)
@@ -84,6 +97,55 @@ func (mr *MockStorerMockRecorder) Update(ctx, newshortened interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockStorer)(nil).Update), ctx, newshortened)
 }
 
+// IncrementHits mocks base method
+func (m *MockStorer) IncrementHits(ctx context.Context, slug string, delta int) error {
+	ret := m.ctrl.Call(m, "IncrementHits", ctx, slug, delta)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementHits indicates an expected call of IncrementHits
+func (mr *MockStorerMockRecorder) IncrementHits(ctx, slug, delta interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementHits", reflect.TypeOf((*MockStorer)(nil).IncrementHits), ctx, slug, delta)
+}
+
+// BulkIncrementHits mocks base method
+func (m *MockStorer) BulkIncrementHits(ctx context.Context, deltas map[string]int) error {
+	ret := m.ctrl.Call(m, "BulkIncrementHits", ctx, deltas)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkIncrementHits indicates an expected call of BulkIncrementHits
+func (mr *MockStorerMockRecorder) BulkIncrementHits(ctx, deltas interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkIncrementHits", reflect.TypeOf((*MockStorer)(nil).BulkIncrementHits), ctx, deltas)
+}
+
+// BulkIncrementBotHits mocks base method
+func (m *MockStorer) BulkIncrementBotHits(ctx context.Context, deltas map[string]int) error {
+	ret := m.ctrl.Call(m, "BulkIncrementBotHits", ctx, deltas)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkIncrementBotHits indicates an expected call of BulkIncrementBotHits
+func (mr *MockStorerMockRecorder) BulkIncrementBotHits(ctx, deltas interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkIncrementBotHits", reflect.TypeOf((*MockStorer)(nil).BulkIncrementBotHits), ctx, deltas)
+}
+
+// ConsumeHit mocks base method
+func (m *MockStorer) ConsumeHit(ctx context.Context, slug string) (models.URLShortened, error) {
+	ret := m.ctrl.Call(m, "ConsumeHit", ctx, slug)
+	ret0, _ := ret[0].(models.URLShortened)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeHit indicates an expected call of ConsumeHit
+func (mr *MockStorerMockRecorder) ConsumeHit(ctx, slug interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeHit", reflect.TypeOf((*MockStorer)(nil).ConsumeHit), ctx, slug)
+}
+
 // Delete mocks base method
 func (m *MockStorer) Delete(ctx context.Context, slug string) error {
 	ret := m.ctrl.Call(m, "Delete", ctx, slug)
@@ -95,3 +157,161 @@ func (m *MockStorer) Delete(ctx context.Context, slug string) error {
 func (mr *MockStorerMockRecorder) Delete(ctx, slug interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStorer)(nil).Delete), ctx, slug)
 }
+
+// MergeVisitors mocks base method
+func (m *MockStorer) MergeVisitors(ctx context.Context, sketches map[string]models.Sketch) error {
+	ret := m.ctrl.Call(m, "MergeVisitors", ctx, sketches)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MergeVisitors indicates an expected call of MergeVisitors
+func (mr *MockStorerMockRecorder) MergeVisitors(ctx, sketches interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeVisitors", reflect.TypeOf((*MockStorer)(nil).MergeVisitors), ctx, sketches)
+}
+
+// Visitors mocks base method
+func (m *MockStorer) Visitors(ctx context.Context, slug string) (models.Sketch, error) {
+	ret := m.ctrl.Call(m, "Visitors", ctx, slug)
+	ret0, _ := ret[0].(models.Sketch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Visitors indicates an expected call of Visitors
+func (mr *MockStorerMockRecorder) Visitors(ctx, slug interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Visitors", reflect.TypeOf((*MockStorer)(nil).Visitors), ctx, slug)
+}
+
+// MockCounter is a mock of Counter interface
+type MockCounter struct {
+	ctrl     *gomock.Controller
+	recorder *MockCounterMockRecorder
+}
+
+// MockCounterMockRecorder is the mock recorder for MockCounter
+type MockCounterMockRecorder struct {
+	mock *MockCounter
+}
+
+// NewMockCounter creates a new mock instance
+func NewMockCounter(ctrl *gomock.Controller) *MockCounter {
+	mock := &MockCounter{ctrl: ctrl}
+	mock.recorder = &MockCounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockCounter) EXPECT() *MockCounterMockRecorder {
+	return m.recorder
+}
+
+// Next mocks base method
+func (m *MockCounter) Next(ctx context.Context, name string) (uint64, error) {
+	ret := m.ctrl.Call(m, "Next", ctx, name)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Next indicates an expected call of Next
+func (mr *MockCounterMockRecorder) Next(ctx, name interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockCounter)(nil).Next), ctx, name)
+}
+
+// MockClickStorer is a mock of ClickStorer interface
+type MockClickStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockClickStorerMockRecorder
+}
+
+// MockClickStorerMockRecorder is the mock recorder for MockClickStorer
+type MockClickStorerMockRecorder struct {
+	mock *MockClickStorer
+}
+
+// NewMockClickStorer creates a new mock instance
+func NewMockClickStorer(ctrl *gomock.Controller) *MockClickStorer {
+	mock := &MockClickStorer{ctrl: ctrl}
+	mock.recorder = &MockClickStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClickStorer) EXPECT() *MockClickStorerMockRecorder {
+	return m.recorder
+}
+
+// AddClicks mocks base method
+func (m *MockClickStorer) AddClicks(ctx context.Context, clicks []models.Click) error {
+	ret := m.ctrl.Call(m, "AddClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddClicks indicates an expected call of AddClicks
+func (mr *MockClickStorerMockRecorder) AddClicks(ctx, clicks interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddClicks", reflect.TypeOf((*MockClickStorer)(nil).AddClicks), ctx, clicks)
+}
+
+// IterateClicks mocks base method
+func (m *MockClickStorer) IterateClicks(ctx context.Context, query ClickQuery, fn func(models.Click) error) error {
+	ret := m.ctrl.Call(m, "IterateClicks", ctx, query, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IterateClicks indicates an expected call of IterateClicks
+func (mr *MockClickStorerMockRecorder) IterateClicks(ctx, query, fn interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IterateClicks", reflect.TypeOf((*MockClickStorer)(nil).IterateClicks), ctx, query, fn)
+}
+
+// ClickStats mocks base method
+func (m *MockClickStorer) ClickStats(ctx context.Context, query ClickQuery, interval models.StatsInterval, top int) (models.ClickStats, error) {
+	ret := m.ctrl.Call(m, "ClickStats", ctx, query, interval, top)
+	ret0, _ := ret[0].(models.ClickStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClickStats indicates an expected call of ClickStats
+func (mr *MockClickStorerMockRecorder) ClickStats(ctx, query, interval, top interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClickStats", reflect.TypeOf((*MockClickStorer)(nil).ClickStats), ctx, query, interval, top)
+}
+
+// MergeDailyVisitors mocks base method
+func (m *MockClickStorer) MergeDailyVisitors(ctx context.Context, sketches []models.DailySketch) error {
+	ret := m.ctrl.Call(m, "MergeDailyVisitors", ctx, sketches)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MergeDailyVisitors indicates an expected call of MergeDailyVisitors
+func (mr *MockClickStorerMockRecorder) MergeDailyVisitors(ctx, sketches interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeDailyVisitors", reflect.TypeOf((*MockClickStorer)(nil).MergeDailyVisitors), ctx, sketches)
+}
+
+// DailyVisitors mocks base method
+func (m *MockClickStorer) DailyVisitors(ctx context.Context, query ClickQuery) ([]models.DailySketch, error) {
+	ret := m.ctrl.Call(m, "DailyVisitors", ctx, query)
+	ret0, _ := ret[0].([]models.DailySketch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DailyVisitors indicates an expected call of DailyVisitors
+func (mr *MockClickStorerMockRecorder) DailyVisitors(ctx, query interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DailyVisitors", reflect.TypeOf((*MockClickStorer)(nil).DailyVisitors), ctx, query)
+}
+
+// DeleteClicks mocks base method
+func (m *MockClickStorer) DeleteClicks(ctx context.Context, slug string) error {
+	ret := m.ctrl.Call(m, "DeleteClicks", ctx, slug)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteClicks indicates an expected call of DeleteClicks
+func (mr *MockClickStorerMockRecorder) DeleteClicks(ctx, slug interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteClicks", reflect.TypeOf((*MockClickStorer)(nil).DeleteClicks), ctx, slug)
+}
@@ -0,0 +1,37 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/indiependente/shrtnr/repository/storertest"
+)
+
+func TestMemoryURLStorer_Conformance(t *testing.T) {
+	t.Parallel()
+	storertest.Run(t, func(t *testing.T) repository.Storer {
+		return repository.NewMemoryURLStorer()
+	})
+}
+
+func TestCachedURLStorer_Conformance(t *testing.T) {
+	t.Parallel()
+	storertest.Run(t, func(t *testing.T) repository.Storer {
+		return repository.NewCachedURLStorer(repository.NewMemoryURLStorer(), 10, time.Minute)
+	})
+}
+
+func TestMemoryCounter_Conformance(t *testing.T) {
+	t.Parallel()
+	storertest.RunCounter(t, func(t *testing.T) repository.Counter {
+		return repository.NewMemoryCounter()
+	})
+}
+
+func TestMemoryClickStorer_Conformance(t *testing.T) {
+	t.Parallel()
+	storertest.RunClickStorer(t, func(t *testing.T) repository.ClickStorer {
+		return repository.NewMemoryClickStorer()
+	})
+}
@@ -0,0 +1,374 @@
+package storertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// ClickStorerFactory returns a new empty ClickStorer, isolated from the ones returned by previous calls.
+// Any cleanup should be registered on t.
+type ClickStorerFactory func(t *testing.T) repository.ClickStorer
+
+// RunClickStorer runs the conformance suite against the ClickStorers returned by newStore.
+func RunClickStorer(t *testing.T, newStore ClickStorerFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newStore ClickStorerFactory)
+	}{
+		{name: "AddClicks", test: testAddClicks},
+		{name: "AddClicksRetried", test: testAddClicksRetried},
+		{name: "IterateClicks", test: testIterateClicks},
+		{name: "IterateClicksStops", test: testIterateClicksStops},
+		{name: "ClickStats", test: testClickStats},
+		{name: "DailyVisitors", test: testDailyVisitors},
+		{name: "DeleteClicks", test: testDeleteClicks},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore)
+		})
+	}
+}
+
+// clicksEpoch is the time of the first click of the suite, truncated to the precision of every backend.
+var clicksEpoch = time.Date(2020, time.October, 18, 12, 0, 0, 0, time.UTC)
+
+// sampleClicks returns clicks on two slugs, a minute apart from each other, out of chronological order.
+func sampleClicks() []models.Click {
+	return []models.Click{
+		{Slug: "pizza", Time: clicksEpoch.Add(2 * time.Minute), Referrer: "https://news.example", UserAgent: "Mozilla/5.0", IPHash: "a1", AcceptLanguage: "en-GB", RequestID: "r3"},
+		{Slug: "pizza", Time: clicksEpoch, IPHash: "a1", RequestID: "r1"},
+		{Slug: "pasta", Time: clicksEpoch.Add(time.Minute), IPHash: "b2", RequestID: "r2"},
+		{Slug: "pizza", Time: clicksEpoch.Add(3 * time.Minute), IPHash: "c3", RequestID: "r4"},
+	}
+}
+
+// iterate returns the request ids of the clicks selected by the query, in the order they are iterated.
+func iterate(ctx context.Context, t *testing.T, store repository.ClickStorer, query repository.ClickQuery) []string {
+	var ids []string
+	err := store.IterateClicks(ctx, query, func(click models.Click) error {
+		ids = append(ids, click.RequestID)
+		return nil
+	})
+	require.NoError(t, err)
+	return ids
+}
+
+func testAddClicks(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+
+	require.NoError(t, store.AddClicks(ctx, nil))
+	clicks := sampleClicks()
+	require.NoError(t, store.AddClicks(ctx, clicks[:2]))
+	require.NoError(t, store.AddClicks(ctx, clicks[2:]))
+
+	var got []models.Click
+	err := store.IterateClicks(ctx, repository.ClickQuery{Slug: "pizza"}, func(click models.Click) error {
+		got = append(got, click)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	require.Equal(t, clicks[1], got[0])
+	require.Equal(t, clicks[0], got[1], "every field is stored")
+	require.True(t, clicks[0].Time.Equal(got[1].Time))
+}
+
+func testAddClicksRetried(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+
+	clicks := sampleClicks()
+	for i := range clicks {
+		clicks[i].ID = fmt.Sprintf("click-%d", i)
+	}
+	require.NoError(t, store.AddClicks(ctx, clicks[:2]))
+	require.NoError(t, store.AddClicks(ctx, clicks), "clicks already stored are skipped")
+	require.NoError(t, store.AddClicks(ctx, clicks))
+	require.Equal(t, []string{"r1", "r2", "r3", "r4"}, iterate(ctx, t, store, repository.ClickQuery{}))
+
+	var got []models.Click
+	err := store.IterateClicks(ctx, repository.ClickQuery{Slug: "pasta"}, func(click models.Click) error {
+		got = append(got, click)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []models.Click{clicks[2]}, got, "the id is stored")
+}
+
+func testIterateClicks(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+	require.NoError(t, store.AddClicks(ctx, sampleClicks()))
+
+	tests := []struct {
+		name  string
+		query repository.ClickQuery
+		want  []string
+	}{
+		{
+			name:  "all",
+			query: repository.ClickQuery{},
+			want:  []string{"r1", "r2", "r3", "r4"},
+		},
+		{
+			name:  "slug",
+			query: repository.ClickQuery{Slug: "pizza"},
+			want:  []string{"r1", "r3", "r4"},
+		},
+		{
+			name:  "from is inclusive",
+			query: repository.ClickQuery{From: clicksEpoch.Add(time.Minute)},
+			want:  []string{"r2", "r3", "r4"},
+		},
+		{
+			name:  "to is exclusive",
+			query: repository.ClickQuery{To: clicksEpoch.Add(2 * time.Minute)},
+			want:  []string{"r1", "r2"},
+		},
+		{
+			name:  "slug and range",
+			query: repository.ClickQuery{Slug: "pizza", From: clicksEpoch.Add(time.Second), To: clicksEpoch.Add(time.Hour)},
+			want:  []string{"r3", "r4"},
+		},
+		{
+			name:  "unknown slug",
+			query: repository.ClickQuery{Slug: "risotto"},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, iterate(ctx, t, store, tt.query), tt.name)
+	}
+}
+
+func testIterateClicksStops(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+	require.NoError(t, store.AddClicks(ctx, sampleClicks()))
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.IterateClicks(ctx, repository.ClickQuery{}, func(models.Click) error {
+		calls++
+		if calls == 2 {
+			return stop
+		}
+		return nil
+	})
+	require.Equal(t, stop, err, "the error of fn is returned as is")
+	require.Equal(t, 2, calls)
+}
+
+func testClickStats(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+	monday := time.Date(2020, time.October, 12, 0, 0, 0, 0, time.UTC)
+	click := func(slug string, at time.Duration, referrer, browser, country string) models.Click {
+		return models.Click{Slug: slug, Time: monday.Add(at), Referrer: referrer, UserAgent: browser + "/1.0", Browser: browser, Country: country}
+	}
+	bot := func(slug string, at time.Duration) models.Click {
+		c := click(slug, at, "https://chat.example", "Slackbot", "US")
+		c.Bot = true
+		return c
+	}
+	err := store.AddClicks(ctx, []models.Click{
+		click("pizza", 10*time.Minute, "https://news.example", "Firefox", "IT"),
+		click("pizza", 50*time.Minute, "https://news.example", "Chrome", "IT"),
+		click("pizza", 2*time.Hour, "", "Chrome", ""),
+		click("pizza", 26*time.Hour, "https://blog.example", "Chrome", "GB"),
+		click("pizza", 8*24*time.Hour, "https://blog.example", "Safari", "FR"),
+		click("pasta", time.Hour, "https://news.example", "Firefox", "IT"),
+		// bots are counted apart and left out of everything else
+		bot("pizza", 20*time.Minute),
+		bot("pizza", 30*time.Hour),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    repository.ClickQuery
+		interval models.StatsInterval
+		top      int
+		want     models.ClickStats
+	}{
+		{
+			name:     "hour",
+			query:    repository.ClickQuery{Slug: "pizza", From: monday, To: monday.Add(24 * time.Hour)},
+			interval: models.IntervalHour,
+			top:      10,
+			want: models.ClickStats{
+				Total: 3,
+				Bots:  1,
+				Buckets: []models.ClickBucket{
+					{Start: monday, Count: 2},
+					{Start: monday.Add(2 * time.Hour), Count: 1},
+				},
+				Referrers:  []models.Tally{{Value: "https://news.example", Count: 2}},
+				UserAgents: []models.Tally{{Value: "Chrome/1.0", Count: 2}, {Value: "Firefox/1.0", Count: 1}},
+				Browsers:   []models.Tally{{Value: "Chrome", Count: 2}, {Value: "Firefox", Count: 1}},
+				Countries:  []models.Tally{{Value: "IT", Count: 2}},
+			},
+		},
+		{
+			name:     "day with top",
+			query:    repository.ClickQuery{Slug: "pizza"},
+			interval: models.IntervalDay,
+			top:      1,
+			want: models.ClickStats{
+				Total: 5,
+				Bots:  2,
+				Buckets: []models.ClickBucket{
+					{Start: monday, Count: 3},
+					{Start: monday.Add(24 * time.Hour), Count: 1},
+					{Start: monday.Add(8 * 24 * time.Hour), Count: 1},
+				},
+				Referrers:  []models.Tally{{Value: "https://blog.example", Count: 2}}, // ties broken by value
+				UserAgents: []models.Tally{{Value: "Chrome/1.0", Count: 3}},
+				Browsers:   []models.Tally{{Value: "Chrome", Count: 3}},
+				Countries:  []models.Tally{{Value: "IT", Count: 2}},
+			},
+		},
+		{
+			name:     "week starting on monday",
+			query:    repository.ClickQuery{Slug: "pizza", From: monday.Add(time.Hour)},
+			interval: models.IntervalWeek,
+			top:      10,
+			want: models.ClickStats{
+				Total: 3,
+				Bots:  1,
+				Buckets: []models.ClickBucket{
+					{Start: monday, Count: 2},
+					{Start: monday.Add(7 * 24 * time.Hour), Count: 1},
+				},
+				Referrers:  []models.Tally{{Value: "https://blog.example", Count: 2}},
+				UserAgents: []models.Tally{{Value: "Chrome/1.0", Count: 2}, {Value: "Safari/1.0", Count: 1}},
+				Browsers:   []models.Tally{{Value: "Chrome", Count: 2}, {Value: "Safari", Count: 1}},
+				Countries:  []models.Tally{{Value: "FR", Count: 1}, {Value: "GB", Count: 1}},
+			},
+		},
+		{
+			name:     "top not positive",
+			query:    repository.ClickQuery{Slug: "pizza", From: monday, To: monday.Add(24 * time.Hour)},
+			interval: models.IntervalDay,
+			top:      0,
+			want: models.ClickStats{
+				Total:      3,
+				Bots:       1,
+				Buckets:    []models.ClickBucket{{Start: monday, Count: 3}},
+				Referrers:  []models.Tally{},
+				UserAgents: []models.Tally{},
+				Browsers:   []models.Tally{},
+				Countries:  []models.Tally{},
+			},
+		},
+		{
+			name:     "top negative",
+			query:    repository.ClickQuery{Slug: "pasta"},
+			interval: models.IntervalDay,
+			top:      -1,
+			want: models.ClickStats{
+				Total:      1,
+				Buckets:    []models.ClickBucket{{Start: monday, Count: 1}},
+				Referrers:  []models.Tally{},
+				UserAgents: []models.Tally{},
+				Browsers:   []models.Tally{},
+				Countries:  []models.Tally{},
+			},
+		},
+		{
+			name:     "no clicks",
+			query:    repository.ClickQuery{Slug: "risotto"},
+			interval: models.IntervalDay,
+			top:      10,
+			want: models.ClickStats{
+				Referrers:  []models.Tally{},
+				UserAgents: []models.Tally{},
+				Browsers:   []models.Tally{},
+				Countries:  []models.Tally{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := store.ClickStats(ctx, tt.query, tt.interval, tt.top)
+		require.NoError(t, err, tt.name)
+		require.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func testDailyVisitors(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+	day := clicksEpoch.Truncate(24 * time.Hour)
+	next := day.Add(24 * time.Hour)
+
+	visitors, err := store.DailyVisitors(ctx, repository.ClickQuery{Slug: "pizza"})
+	require.NoError(t, err)
+	require.Empty(t, visitors)
+
+	// empty batches are no-ops, registers keep their highest rank
+	require.NoError(t, store.MergeDailyVisitors(ctx, nil))
+	err = store.MergeDailyVisitors(ctx, []models.DailySketch{
+		{Slug: "pizza", Day: next, Sketch: models.Sketch{7: 1}},
+		{Slug: "pizza", Day: day, Sketch: models.Sketch{1: 3, 5: 2}},
+		{Slug: "pasta", Day: day, Sketch: models.Sketch{2: 4}},
+	})
+	require.NoError(t, err)
+	err = store.MergeDailyVisitors(ctx, []models.DailySketch{
+		{Slug: "pizza", Day: day, Sketch: models.Sketch{1: 2, 16383: 50}},
+	})
+	require.NoError(t, err)
+
+	visitors, err = store.DailyVisitors(ctx, repository.ClickQuery{Slug: "pizza"})
+	require.NoError(t, err)
+	require.Equal(t, []models.DailySketch{
+		{Slug: "pizza", Day: day, Sketch: models.Sketch{1: 3, 5: 2, 16383: 50}},
+		{Slug: "pizza", Day: next, Sketch: models.Sketch{7: 1}},
+	}, visitors)
+
+	visitors, err = store.DailyVisitors(ctx, repository.ClickQuery{From: day, To: next})
+	require.NoError(t, err)
+	require.Equal(t, []models.DailySketch{
+		{Slug: "pasta", Day: day, Sketch: models.Sketch{2: 4}},
+		{Slug: "pizza", Day: day, Sketch: models.Sketch{1: 3, 5: 2, 16383: 50}},
+	}, visitors)
+}
+
+func testDeleteClicks(t *testing.T, newStore ClickStorerFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStore(t)
+	day := clicksEpoch.Truncate(24 * time.Hour)
+
+	require.NoError(t, store.DeleteClicks(ctx, "pizza"), "deleting nothing is not an error")
+	require.NoError(t, store.AddClicks(ctx, sampleClicks()))
+	err := store.MergeDailyVisitors(ctx, []models.DailySketch{
+		{Slug: "pizza", Day: day, Sketch: models.Sketch{1: 3}},
+		{Slug: "pasta", Day: day, Sketch: models.Sketch{2: 4}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteClicks(ctx, "pizza"))
+	require.Equal(t, []string{"r2"}, iterate(ctx, t, store, repository.ClickQuery{}))
+	visitors, err := store.DailyVisitors(ctx, repository.ClickQuery{})
+	require.NoError(t, err)
+	require.Equal(t, []models.DailySketch{{Slug: "pasta", Day: day, Sketch: models.Sketch{2: 4}}}, visitors)
+	stats, err := store.ClickStats(ctx, repository.ClickQuery{Slug: "pizza"}, models.IntervalDay, 5)
+	require.NoError(t, err)
+	require.Zero(t, stats.Total)
+}
@@ -0,0 +1,74 @@
+package storertest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// CounterFactory returns a new empty Counter, isolated from the ones returned by previous calls.
+// Any cleanup should be registered on t.
+type CounterFactory func(t *testing.T) repository.Counter
+
+// RunCounter runs the conformance suite against the Counters returned by newCounter.
+func RunCounter(t *testing.T, newCounter CounterFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newCounter CounterFactory)
+	}{
+		{name: "Next", test: testNext},
+		{name: "ConcurrentNext", test: testConcurrentNext},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newCounter)
+		})
+	}
+}
+
+func testNext(t *testing.T, newCounter CounterFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	counter := newCounter(t)
+
+	for want := uint64(1); want <= 3; want++ {
+		got, err := counter.Next(ctx, "slugs")
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	got, err := counter.Next(ctx, "clicks")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), got, "counters are independent of each other")
+}
+
+func testConcurrentNext(t *testing.T, newCounter CounterFactory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	counter := newCounter(t)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[uint64]bool{}
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := counter.Next(ctx, "slugs")
+			require.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			require.False(t, seen[n], "%d handed out twice", n)
+			seen[n] = true
+		}()
+	}
+	wg.Wait()
+	for n := uint64(1); n <= concurrency; n++ {
+		require.True(t, seen[n], "%d never handed out", n)
+	}
+}
@@ -0,0 +1,846 @@
+// Package storertest provides conformance test suites for repository.Storer, repository.Counter and repository.ClickStorer implementations.
+// Every backend should be verified against it, so that they all behave the same way.
+package storertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// concurrency is the number of goroutines racing against each other in the concurrent tests.
+	concurrency = 50
+	// timeout is the time each test has to complete.
+	timeout = 20 * time.Second
+)
+
+// expiresAt is an expiration time every backend can store without losing precision.
+var expiresAt = time.Date(2030, time.January, 1, 12, 30, 0, 0, time.UTC)
+
+// passwordHash is the bcrypt hash of "pizza", backends store it as an opaque string.
+const passwordHash = "$2a$04$VnZL2/aZkIogeRuueR39ouFBuNRkgwuDnveqL3SLrV0ArMvaRexOW"
+
+// Factory returns a new empty Storer, isolated from the ones returned by previous calls.
+// Any cleanup should be registered on t.
+type Factory func(t *testing.T) repository.Storer
+
+// Run runs the conformance suite against the Storers returned by newStorer.
+func Run(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newStorer Factory)
+	}{
+		{name: "Add", test: testAdd},
+		{name: "Get", test: testGet},
+		{name: "GetURL", test: testGetURL},
+		{name: "GetShareableURL", test: testGetShareableURL},
+		{name: "Update", test: testUpdate},
+		{name: "IncrementHits", test: testIncrementHits},
+		{name: "BulkIncrementHits", test: testBulkIncrementHits},
+		{name: "BulkIncrementBotHits", test: testBulkIncrementBotHits},
+		{name: "ConsumeHit", test: testConsumeHit},
+		{name: "Delete", test: testDelete},
+		{name: "Visitors", test: testVisitors},
+		{name: "ConcurrentAdd", test: testConcurrentAdd},
+		{name: "ConcurrentUpdate", test: testConcurrentUpdate},
+		{name: "ConcurrentAddDelete", test: testConcurrentAddDelete},
+		{name: "ConcurrentIncrementHits", test: testConcurrentIncrementHits},
+		{name: "ConcurrentConsumeHit", test: testConcurrentConsumeHit},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorer)
+		})
+	}
+}
+
+func testAdd(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		url        models.URLShortened
+		setupStore func(ctx context.Context, store repository.Storer) error
+		err        error
+	}{
+		{
+			name: "Happy path",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - same url different slug",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "pizza"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - with expiration",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", ExpiresAt: &expiresAt},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - with password",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", PasswordHash: passwordHash},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - with folded slug",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", FoldedSlug: true},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: nil,
+		},
+		{
+			name: "Sad path - existing slug",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://indiependente.dev", Slug: "aeiou"})
+			},
+			err: repository.ErrSlugAlreadyInUse,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			err := store.Add(ctx, tt.url)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			if err != nil {
+				return
+			}
+			url, err := store.Get(ctx, tt.url.Slug)
+			require.NoError(t, err)
+			require.Equal(t, tt.url, url)
+		})
+	}
+}
+
+func testGet(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		slug       string
+		setupStore func(ctx context.Context, store repository.Storer) error
+		wantURL    models.URLShortened
+		err        error
+	}{
+		{
+			name: "Happy path",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3})
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3},
+			err:     nil,
+		},
+		{
+			name: "Sad path - slug not found",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "pizza"})
+			},
+			wantURL: models.URLShortened{},
+			err:     repository.ErrSlugNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			url, err := store.Get(ctx, tt.slug)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			require.Equal(t, tt.wantURL, url)
+		})
+	}
+}
+
+func testGetURL(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		url        string
+		setupStore func(ctx context.Context, store repository.Storer) error
+		wantURL    models.URLShortened
+		err        error
+	}{
+		{
+			name: "Happy path",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3})
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3},
+			err:     nil,
+		},
+		{
+			name: "Happy path - url still shortened by another slug after delete",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				err := store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+				if err != nil {
+					return err
+				}
+				err = store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "pizza"})
+				if err != nil {
+					return err
+				}
+				return store.Delete(ctx, "aeiou")
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "pizza"},
+			err:     nil,
+		},
+		{
+			name: "Sad path - url not found",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://indiependente.dev", Slug: "aeiou"})
+			},
+			wantURL: models.URLShortened{},
+			err:     repository.ErrURLNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			url, err := store.GetURL(ctx, tt.url)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			require.Equal(t, tt.wantURL, url)
+		})
+	}
+}
+
+func testGetShareableURL(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		url        string
+		setupStore func(ctx context.Context, store repository.Storer) error
+		wantURL    models.URLShortened
+		err        error
+	}{
+		{
+			name: "Happy path",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3})
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3},
+			err:     nil,
+		},
+		{
+			name: "Happy path - shortened urls with per link settings are skipped",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				for _, short := range []models.URLShortened{
+					{URL: "https://shrtnr.dev", Slug: "expiring", ExpiresAt: &expiresAt},
+					{URL: "https://shrtnr.dev", Slug: "limited", MaxHits: 1},
+					{URL: "https://shrtnr.dev", Slug: "protected", PasswordHash: passwordHash},
+					{URL: "https://shrtnr.dev", Slug: "moved", RedirectStatus: http.StatusMovedPermanently},
+					{URL: "https://shrtnr.dev", Slug: "pizza"},
+				} {
+					if err := store.Add(ctx, short); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "pizza"},
+			err:     nil,
+		},
+		{
+			name: "Sad path - only shortened urls with per link settings",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "limited", MaxHits: 1})
+			},
+			wantURL: models.URLShortened{},
+			err:     repository.ErrURLNotFound,
+		},
+		{
+			name: "Sad path - url not found",
+			url:  "https://shrtnr.dev",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://indiependente.dev", Slug: "aeiou"})
+			},
+			wantURL: models.URLShortened{},
+			err:     repository.ErrURLNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			url, err := store.GetShareableURL(ctx, tt.url)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			require.Equal(t, tt.wantURL, url)
+		})
+	}
+}
+
+func testUpdate(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		url        models.URLShortened
+		setupStore func(ctx context.Context, store repository.Storer) error
+		err        error
+	}{
+		{
+			name: "Happy path",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 1},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - set expiration",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", ExpiresAt: &expiresAt},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - clear expiration",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", ExpiresAt: &expiresAt})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - set redirect status",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", RedirectStatus: http.StatusFound},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - clear redirect status",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", RedirectStatus: http.StatusFound})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - clear password",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", PasswordHash: passwordHash})
+			},
+			err: nil,
+		},
+		{
+			name: "Happy path - new url",
+			url:  models.URLShortened{URL: "https://indiependente.dev", Slug: "aeiou", Hits: 1},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			},
+			err: nil,
+		},
+		{
+			name: "Sad path - slug not found",
+			url:  models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"},
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: repository.ErrSlugNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			err := store.Update(ctx, tt.url)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			if err != nil {
+				_, err = store.Get(ctx, tt.url.Slug)
+				require.True(t, errors.Is(err, repository.ErrSlugNotFound), "update must not create entries")
+				return
+			}
+			url, err := store.Get(ctx, tt.url.Slug)
+			require.NoError(t, err)
+			require.Equal(t, tt.url, url)
+			url, err = store.GetURL(ctx, tt.url.URL)
+			require.NoError(t, err)
+			require.Equal(t, tt.url, url)
+		})
+	}
+}
+
+func testIncrementHits(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		slug       string
+		delta      int
+		setupStore func(ctx context.Context, store repository.Storer) error
+		wantHits   int
+		err        error
+	}{
+		{
+			name:  "Happy path",
+			slug:  "aeiou",
+			delta: 1,
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3})
+			},
+			wantHits: 4,
+			err:      nil,
+		},
+		{
+			name:  "Happy path - delta greater than one",
+			slug:  "aeiou",
+			delta: 10,
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3})
+			},
+			wantHits: 13,
+			err:      nil,
+		},
+		{
+			name:  "Sad path - slug not found",
+			slug:  "aeiou",
+			delta: 1,
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: repository.ErrSlugNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			err := store.IncrementHits(ctx, tt.slug, tt.delta)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			if err != nil {
+				return
+			}
+			url, err := store.Get(ctx, tt.slug)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantHits, url.Hits)
+		})
+	}
+}
+
+func testBulkIncrementHits(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3}))
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://indiependente.dev", Slug: "pizza"}))
+
+	// empty batches are no-ops
+	require.NoError(t, store.BulkIncrementHits(ctx, nil))
+	// missing slugs are skipped
+	err := store.BulkIncrementHits(ctx, map[string]int{
+		"aeiou": 2,
+		"pizza": 5,
+		"gone":  1,
+	})
+	require.NoError(t, err)
+
+	url, err := store.Get(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Equal(t, 5, url.Hits)
+	url, err = store.Get(ctx, "pizza")
+	require.NoError(t, err)
+	require.Equal(t, 5, url.Hits)
+	_, err = store.Get(ctx, "gone")
+	require.True(t, errors.Is(err, repository.ErrSlugNotFound), "bulk increment must not create entries")
+}
+
+func testVisitors(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"}))
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://indiependente.dev", Slug: "pizza"}))
+
+	visitors, err := store.Visitors(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Empty(t, visitors)
+
+	// empty batches are no-ops
+	require.NoError(t, store.MergeVisitors(ctx, nil))
+	// missing slugs are skipped, registers keep their highest rank
+	err = store.MergeVisitors(ctx, map[string]models.Sketch{
+		"aeiou": {1: 3, 5: 2},
+		"pizza": {},
+		"gone":  {1: 1},
+	})
+	require.NoError(t, err)
+	err = store.MergeVisitors(ctx, map[string]models.Sketch{
+		"aeiou": {1: 2, 16383: 50},
+	})
+	require.NoError(t, err)
+
+	visitors, err = store.Visitors(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Equal(t, models.Sketch{1: 3, 5: 2, 16383: 50}, visitors)
+	visitors, err = store.Visitors(ctx, "pizza")
+	require.NoError(t, err)
+	require.Empty(t, visitors)
+	_, err = store.Visitors(ctx, "gone")
+	require.True(t, errors.Is(err, repository.ErrSlugNotFound), "merge must not create entries")
+
+	// visitors are not touched by updates, nor read with the shortened url
+	url, err := store.Get(ctx, "aeiou")
+	require.NoError(t, err)
+	url.URL = "https://shrtnr.dev/new"
+	require.NoError(t, store.Update(ctx, url))
+	visitors, err = store.Visitors(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Len(t, visitors, 3)
+
+	// visitors are deleted with the shortened url
+	require.NoError(t, store.Delete(ctx, "aeiou"))
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"}))
+	visitors, err = store.Visitors(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Empty(t, visitors)
+}
+
+func testBulkIncrementBotHits(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3}))
+
+	// empty batches are no-ops
+	require.NoError(t, store.BulkIncrementBotHits(ctx, nil))
+	// missing slugs are skipped
+	err := store.BulkIncrementBotHits(ctx, map[string]int{
+		"aeiou": 2,
+		"gone":  1,
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.BulkIncrementBotHits(ctx, map[string]int{"aeiou": 1}))
+
+	url, err := store.Get(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Equal(t, 3, url.BotHits)
+	require.Equal(t, 3, url.Hits, "bot hits are counted apart")
+	_, err = store.Get(ctx, "gone")
+	require.True(t, errors.Is(err, repository.ErrSlugNotFound), "bulk increment must not create entries")
+}
+
+func testConsumeHit(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		slug       string
+		setupStore func(ctx context.Context, store repository.Storer) error
+		wantURL    models.URLShortened
+		err        error
+	}{
+		{
+			name: "Happy path - no max hits",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3})
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 4},
+			err:     nil,
+		},
+		{
+			name: "Happy path - below max hits",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 2, MaxHits: 3})
+			},
+			wantURL: models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3, MaxHits: 3},
+			err:     nil,
+		},
+		{
+			name: "Sad path - max hits reached",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: 3, MaxHits: 3})
+			},
+			wantURL: models.URLShortened{},
+			err:     repository.ErrHitLimitReached,
+		},
+		{
+			name: "Sad path - slug not found",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			wantURL: models.URLShortened{},
+			err:     repository.ErrSlugNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			url, err := store.ConsumeHit(ctx, tt.slug)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			require.Equal(t, tt.wantURL, url)
+		})
+	}
+}
+
+func testDelete(t *testing.T, newStorer Factory) {
+	tests := []struct {
+		name       string
+		slug       string
+		setupStore func(ctx context.Context, store repository.Storer) error
+		err        error
+	}{
+		{
+			name: "Happy path",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			},
+			err: nil,
+		},
+		{
+			name: "Sad path - slug not found",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				return nil
+			},
+			err: repository.ErrSlugNotFound,
+		},
+		{
+			name: "Sad path - already deleted",
+			slug: "aeiou",
+			setupStore: func(ctx context.Context, store repository.Storer) error {
+				err := store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+				if err != nil {
+					return err
+				}
+				return store.Delete(ctx, "aeiou")
+			},
+			err: repository.ErrSlugNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			store := newStorer(t)
+			require.NoError(t, tt.setupStore(ctx, store))
+			err := store.Delete(ctx, tt.slug)
+			require.True(t, errors.Is(err, tt.err), "got error %v, want %v", err, tt.err)
+			_, err = store.Get(ctx, tt.slug)
+			require.True(t, errors.Is(err, repository.ErrSlugNotFound))
+			_, err = store.GetURL(ctx, "https://shrtnr.dev")
+			require.True(t, errors.Is(err, repository.ErrURLNotFound))
+		})
+	}
+}
+
+// testConcurrentAdd checks that only one of many simultaneous inserts of the same slug wins.
+func testConcurrentAdd(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.Add(ctx, models.URLShortened{
+				URL:  fmt.Sprintf("https://shrtnr.dev/%d", i),
+				Slug: "aeiou",
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	added := 0
+	for err := range errs {
+		if err == nil {
+			added++
+			continue
+		}
+		require.True(t, errors.Is(err, repository.ErrSlugAlreadyInUse), "got error %v, want %v", err, repository.ErrSlugAlreadyInUse)
+	}
+	require.Equal(t, 1, added)
+}
+
+// testConcurrentUpdate checks that concurrent updates of the same slug never fail
+// and leave the entry in the state written by one of them.
+func testConcurrentUpdate(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"}))
+
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 1; i <= concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.Update(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", Hits: i})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	url, err := store.Get(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Equal(t, "https://shrtnr.dev", url.URL)
+	require.True(t, url.Hits >= 1 && url.Hits <= concurrency, "unexpected hits %d", url.Hits)
+}
+
+// testConcurrentAddDelete checks that racing adds and deletes of the same slug
+// only ever fail with the documented errors and leave a consistent state behind.
+func testConcurrentAddDelete(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+
+	errs := make(chan error, 2*concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			err := store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"})
+			if errors.Is(err, repository.ErrSlugAlreadyInUse) {
+				err = nil
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			err := store.Delete(ctx, "aeiou")
+			if errors.Is(err, repository.ErrSlugNotFound) {
+				err = nil
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	url, err := store.Get(ctx, "aeiou")
+	switch {
+	case errors.Is(err, repository.ErrSlugNotFound):
+		_, err = store.GetURL(ctx, "https://shrtnr.dev")
+		require.True(t, errors.Is(err, repository.ErrURLNotFound))
+	case err == nil:
+		require.Equal(t, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"}, url)
+	default:
+		require.NoError(t, err)
+	}
+}
+
+// testConcurrentIncrementHits checks that concurrent increments are never lost.
+func testConcurrentIncrementHits(t *testing.T, newStorer Factory) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou"}))
+
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.IncrementHits(ctx, "aeiou", 2)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	url, err := store.Get(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Equal(t, 2*concurrency, url.Hits)
+}
+
+// testConcurrentConsumeHit checks that concurrent consumers never exceed the max hits.
+func testConcurrentConsumeHit(t *testing.T, newStorer Factory) {
+	const maxHits = 5
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	store := newStorer(t)
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "https://shrtnr.dev", Slug: "aeiou", MaxHits: maxHits}))
+
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.ConsumeHit(ctx, "aeiou")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	consumed := 0
+	for err := range errs {
+		if err == nil {
+			consumed++
+			continue
+		}
+		require.True(t, errors.Is(err, repository.ErrHitLimitReached), "got error %v, want %v", err, repository.ErrHitLimitReached)
+	}
+	require.Equal(t, maxHits, consumed)
+	url, err := store.Get(ctx, "aeiou")
+	require.NoError(t, err)
+	require.Equal(t, maxHits, url.Hits)
+}
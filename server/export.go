@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/service"
+)
+
+const (
+	// FormatCSV exports clicks as CSV, with a header row.
+	FormatCSV = `csv`
+	// FormatNDJSON exports clicks as newline delimited JSON, a click per line.
+	FormatNDJSON = `ndjson`
+)
+
+// exportContentTypes are the content types of the export formats.
+var exportContentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// csvHeader names the columns of the clicks exported as CSV, as their JSON fields.
+var csvHeader = []string{"time", "slug", "referrer", "user_agent", "browser", "country", "accept_language", "ip_hash", "request_id", "bot"}
+
+// exportContextFunc returns the context of an export and the function cancelling it once the export is over.
+type exportContextFunc func() (context.Context, context.CancelFunc)
+
+// exportClicks streams the clicks on the shortened url, over the RFC 3339 from and to query parameters,
+// in the format query parameter, CSV by default.
+func exportClicks(svc service.Service, log logger.Logger, exportContext exportContextFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format, query, err := exportRequest(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		}
+		slug := utils.CopyString(c.Params("slug"))
+		iterate, err := svc.ExportClicks(c.Context(), slug, query)
+		return sendClicks(c, log, exportContext, slug+"-clicks."+format, format, iterate, err)
+	}
+}
+
+// exportAllClicks streams the clicks on every shortened url, over the required RFC 3339 from and to query parameters,
+// in the format query parameter, CSV by default.
+func exportAllClicks(svc service.Service, log logger.Logger, exportContext exportContextFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format, query, err := exportRequest(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		}
+		iterate, err := svc.ExportAllClicks(c.Context(), query)
+		return sendClicks(c, log, exportContext, "clicks."+format, format, iterate, err)
+	}
+}
+
+// exportRequest parses the format and the time range of an export request.
+// Returns an error if any.
+func exportRequest(c *fiber.Ctx) (string, service.ExportQuery, error) {
+	format := c.Query("format", FormatCSV)
+	if _, ok := exportContentTypes[format]; !ok {
+		return "", service.ExportQuery{}, fmt.Errorf("format %q not supported, use %s or %s", format, FormatCSV, FormatNDJSON)
+	}
+	var query service.ExportQuery
+	err := parseTimes(c, map[string]*time.Time{"from": &query.From, "to": &query.To})
+	if err != nil {
+		return "", service.ExportQuery{}, err
+	}
+	return utils.CopyString(format), query, nil
+}
+
+// sendClicks streams the clicks of the iterator as an attachment, unless err is not nil.
+// The response is written while the clicks are iterated, after the handler returned, so the iteration can not be
+// bound to the request context but to the export one instead, and its errors can only be logged, leaving the attachment truncated.
+func sendClicks(c *fiber.Ctx, log logger.Logger, exportContext exportContextFunc, filename, format string, iterate service.ClickIterator, err error) error {
+	switch {
+	case errors.Is(err, service.ErrSlugNotFound):
+		return c.SendStatus(http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidSlug):
+		return c.SendStatus(http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidExportQuery):
+		return c.Status(http.StatusBadRequest).SendString(err.Error())
+	case err != nil:
+		return c.Status(http.StatusInternalServerError).SendString(err.Error())
+	default: // all good
+		c.Attachment(filename)
+		c.Set(fiber.HeaderContentType, exportContentTypes[format])
+		c.Status(http.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			ctx, cancel := exportContext()
+			defer cancel()
+			enc := newClickEncoder(format, w)
+			err := iterate(ctx, enc.Encode)
+			if err == nil {
+				err = enc.Flush()
+			}
+			if err != nil {
+				log.Error("could not export clicks", err)
+			}
+		})
+		return nil
+	}
+}
+
+// clickEncoder writes clicks in an export format.
+type clickEncoder interface {
+	Encode(click models.Click) error
+	Flush() error
+}
+
+// newClickEncoder returns the clickEncoder of the format writing to w.
+func newClickEncoder(format string, w *bufio.Writer) clickEncoder {
+	if format == FormatNDJSON {
+		return ndjsonEncoder{enc: json.NewEncoder(w), w: w}
+	}
+	return &csvEncoder{w: csv.NewWriter(w), flush: w.Flush}
+}
+
+// ndjsonEncoder writes clicks as newline delimited JSON.
+type ndjsonEncoder struct {
+	enc *json.Encoder
+	w   *bufio.Writer
+}
+
+// Encode writes the click as a line of JSON.
+// Returns an error if any.
+func (e ndjsonEncoder) Encode(click models.Click) error {
+	return e.enc.Encode(click)
+}
+
+// Flush writes the buffered lines.
+// Returns an error if any.
+func (e ndjsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// csvEncoder writes clicks as CSV, starting with the header row.
+type csvEncoder struct {
+	w      *csv.Writer
+	flush  func() error
+	header bool
+}
+
+// Encode writes the click as a CSV row, after the header if not written yet.
+// Returns an error if any.
+func (e *csvEncoder) Encode(click models.Click) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		click.Time.UTC().Format(time.RFC3339Nano),
+		csvCell(click.Slug),
+		csvCell(click.Referrer),
+		csvCell(click.UserAgent),
+		csvCell(click.Browser),
+		csvCell(click.Country),
+		csvCell(click.AcceptLanguage),
+		csvCell(click.IPHash),
+		csvCell(click.RequestID),
+		strconv.FormatBool(click.Bot),
+	})
+}
+
+// csvCell neutralizes the value sent by a visitor, so that spreadsheets opening the export do not run it as a formula:
+// values starting with =, +, -, @, a tab or a carriage return are prefixed with a single quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Flush writes the buffered rows, and the header if there were none.
+// Returns an error if any.
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		return err
+	}
+	return e.flush()
+}
+
+// writeHeader writes the header row, once.
+// Returns an error if any.
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(csvHeader)
+}
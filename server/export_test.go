@@ -0,0 +1,258 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportClicks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	from := time.Date(2020, time.October, 12, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	clicks := []models.Click{
+		{Slug: "pizza", Time: from.Add(time.Minute), Referrer: "https://news.example", UserAgent: `Mozilla/5.0 (X11; Linux x86_64) "quoted"`,
+			Browser: "Firefox", Country: "IT", AcceptLanguage: "it-IT,it;q=0.9", IPHash: "a1", RequestID: "r1"},
+		{Slug: "pizza", Time: from.Add(time.Hour), UserAgent: "Googlebot/2.1", Browser: "Other", RequestID: "r2", Bot: true},
+	}
+	iterate := func(ctx context.Context, fn func(models.Click) error) error {
+		for _, click := range clicks {
+			if err := fn(click); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	empty := func(context.Context, func(models.Click) error) error { return nil }
+
+	tests := []struct {
+		name       string
+		path       string
+		setupExpec func(*service.MockService)
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{
+			name: "Happy Path - csv",
+			path: URLShortenPath + "/pizza/clicks/export?from=2020-10-12T00:00:00Z&to=2020-10-13T00:00:00Z",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportClicks(gomock.Any(), "pizza", service.ExportQuery{From: from, To: to}).
+					Return(service.ClickIterator(iterate), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "text/csv; charset=utf-8",
+			wantBody: "time,slug,referrer,user_agent,browser,country,accept_language,ip_hash,request_id,bot\n" +
+				`2020-10-12T00:01:00Z,pizza,https://news.example,"Mozilla/5.0 (X11; Linux x86_64) ""quoted""",Firefox,IT,"it-IT,it;q=0.9",a1,r1,false` + "\n" +
+				"2020-10-12T01:00:00Z,pizza,,Googlebot/2.1,Other,,,,r2,true\n",
+		},
+		{
+			name: "Happy Path - csv formulas neutralized",
+			path: URLShortenPath + "/pizza/clicks/export",
+			setupExpec: func(svc *service.MockService) {
+				formula := models.Click{Slug: "pizza", Time: from, Referrer: `=HYPERLINK("https://evil.example")`, UserAgent: "+cmd",
+					Country: "@SUM(A1)", AcceptLanguage: "-1", RequestID: "\tr3"}
+				svc.EXPECT().ExportClicks(gomock.Any(), "pizza", service.ExportQuery{}).
+					Return(service.ClickIterator(func(ctx context.Context, fn func(models.Click) error) error {
+						return fn(formula)
+					}), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "text/csv; charset=utf-8",
+			wantBody: "time,slug,referrer,user_agent,browser,country,accept_language,ip_hash,request_id,bot\n" +
+				`2020-10-12T00:00:00Z,pizza,"'=HYPERLINK(""https://evil.example"")",'+cmd,,'@SUM(A1),'-1,,'` + "\tr3,false\n",
+		},
+		{
+			name: "Happy Path - ndjson",
+			path: URLShortenPath + "/pizza/clicks/export?format=ndjson",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportClicks(gomock.Any(), "pizza", service.ExportQuery{}).Return(service.ClickIterator(iterate), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "application/x-ndjson",
+			wantBody: `{"slug":"pizza","time":"2020-10-12T00:01:00Z","referrer":"https://news.example",` +
+				`"user_agent":"Mozilla/5.0 (X11; Linux x86_64) \"quoted\"","ip_hash":"a1","accept_language":"it-IT,it;q=0.9",` +
+				`"request_id":"r1","browser":"Firefox","country":"IT"}` + "\n" +
+				`{"slug":"pizza","time":"2020-10-12T01:00:00Z","user_agent":"Googlebot/2.1","request_id":"r2","browser":"Other","bot":true}` + "\n",
+		},
+		{
+			name: "Happy Path - no clicks",
+			path: URLShortenPath + "/pizza/clicks/export?format=csv",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportClicks(gomock.Any(), "pizza", service.ExportQuery{}).Return(service.ClickIterator(empty), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "text/csv; charset=utf-8",
+			wantBody:   "time,slug,referrer,user_agent,browser,country,accept_language,ip_hash,request_id,bot\n",
+		},
+		{
+			name: "Happy Path - all links",
+			path: URLShortenPath + "/clicks/export?format=ndjson&from=2020-10-12T00:00:00Z&to=2020-10-13T00:00:00Z",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportAllClicks(gomock.Any(), service.ExportQuery{From: from, To: to}).Return(service.ClickIterator(empty), nil)
+			},
+			wantStatus: http.StatusOK,
+			wantType:   "application/x-ndjson",
+			wantBody:   "",
+		},
+		{
+			name:       "Sad Path - format not supported",
+			path:       URLShortenPath + "/pizza/clicks/export?format=xml",
+			setupExpec: func(svc *service.MockService) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Sad Path - to not valid",
+			path:       URLShortenPath + "/clicks/export?from=2020-10-12T00:00:00Z&to=tomorrow",
+			setupExpec: func(svc *service.MockService) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Sad Path - query not valid",
+			path: URLShortenPath + "/clicks/export",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportAllClicks(gomock.Any(), service.ExportQuery{}).Return(nil, service.ErrInvalidExportQuery)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Sad Path - slug not found",
+			path: URLShortenPath + "/pasta/clicks/export",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportClicks(gomock.Any(), "pasta", service.ExportQuery{}).Return(nil, service.ErrSlugNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Sad Path - internal error",
+			path: URLShortenPath + "/pizza/clicks/export",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().ExportClicks(gomock.Any(), "pizza", service.ExportQuery{}).Return(nil, errors.New("boom"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockSvc := service.NewMockService(ctrl)
+			tt.setupExpec(mockSvc)
+
+			app := fiber.New(fiber.Config{
+				CaseSensitive: true,
+				StrictRouting: true,
+				ServerHeader:  "Fiber",
+			})
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(app, mockSvc, 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED))
+			require.NoError(t, err)
+			err = srv.Setup(ctx)
+			require.NoError(t, err)
+
+			// send the request to the app, without listening
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			require.NoError(t, err)
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantType, resp.Header.Get(fiber.HeaderContentType))
+			require.Contains(t, resp.Header.Get(fiber.HeaderContentDisposition), "attachment")
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
+
+func TestExportClicksCancelled(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		opts    []Option
+		cancel  func(context.Context, HTTPServer) error
+		wantErr error
+	}{
+		{
+			name:    "Timeout",
+			opts:    []Option{WithExportTimeout(time.Millisecond)},
+			cancel:  func(context.Context, HTTPServer) error { return nil },
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "Shutdown",
+			cancel:  func(ctx context.Context, srv HTTPServer) error { return srv.Shutdown(ctx) },
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockSvc := service.NewMockService(ctrl)
+			started := make(chan struct{})
+			iterErr := make(chan error, 1)
+			mockSvc.EXPECT().ExportClicks(gomock.Any(), "pizza", service.ExportQuery{}).
+				Return(service.ClickIterator(func(ctx context.Context, fn func(models.Click) error) error {
+					close(started)
+					// a slow storage, only stopped by the export context
+					<-ctx.Done()
+					iterErr <- ctx.Err()
+					return ctx.Err()
+				}), nil)
+
+			app := fiber.New()
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(app, mockSvc, 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED), tt.opts...)
+			require.NoError(t, err)
+			err = srv.Setup(ctx)
+			require.NoError(t, err)
+
+			go func() {
+				req, err := http.NewRequest(http.MethodGet, URLShortenPath+"/pizza/clicks/export", nil)
+				if err != nil {
+					return
+				}
+				resp, err := app.Test(req, -1)
+				if err == nil {
+					resp.Body.Close() // nolint: errcheck
+				}
+			}()
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatal("export not started")
+			}
+			require.NoError(t, tt.cancel(ctx, srv))
+			select {
+			case err := <-iterErr:
+				require.ErrorIs(t, err, tt.wantErr)
+			case <-time.After(5 * time.Second):
+				t.Fatal("export not cut short")
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/indiependente/shrtnr/models"
 	"github.com/indiependente/shrtnr/service"
 )
@@ -13,11 +16,17 @@ func getURL(svc service.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		slug := c.Params("slug")
 		url, err := svc.Get(c.Context(), slug)
+		if url.Protected() {
+			// the original url is what the password protects
+			url.URL = ""
+		}
 		switch {
 		case errors.Is(err, service.ErrSlugNotFound):
 			return c.SendStatus(http.StatusNotFound)
 		case errors.Is(err, service.ErrInvalidSlug):
 			return c.SendStatus(http.StatusBadRequest)
+		case errors.Is(err, service.ErrURLExpired):
+			return c.SendStatus(http.StatusGone)
 		case err != nil:
 			return c.Status(http.StatusInternalServerError).SendString(err.Error())
 		default: // all good
@@ -41,6 +50,11 @@ func putURL(svc service.Service) fiber.Handler {
 			return c.Status(http.StatusBadRequest).SendString(err.Error())
 		case errors.Is(err, service.ErrInvalidSlug):
 			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		case errors.Is(err, service.ErrSlugReserved):
+			return c.Status(http.StatusUnprocessableEntity).SendString(err.Error())
+		case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrURLBlocked), errors.Is(err, service.ErrInvalidExpiration),
+			errors.Is(err, service.ErrInvalidMaxHits), errors.Is(err, service.ErrInvalidRedirectStatus), errors.Is(err, service.ErrInvalidPassword):
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
 		case err != nil:
 			return c.Status(http.StatusInternalServerError).SendString(err.Error())
 		default: // all good
@@ -69,23 +83,136 @@ func delURL(svc service.Service) fiber.Handler {
 	}
 }
 
-func resolveURL(svc service.Service) fiber.Handler {
+func resolveURL(svc service.Service, redirectStatus int, bots *service.BotClassifier, record recordFunc) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		slug := c.Params("slug")
-		url, err := svc.Get(c.Context(), slug)
+		return resolve(c, svc, "", bots, record, func(c *fiber.Ctx, url models.URLShortened) error {
+			return redirect(c, url, redirectStatus)
+		})
+	}
+}
+
+// unlockURL resolves a password protected shortened url with the password posted by the password form.
+// It redirects with 303 See Other, so that the browser follows up with a GET.
+func unlockURL(svc service.Service, bots *service.BotClassifier, record recordFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return resolve(c, svc, c.FormValue("password"), bots, record, seeOther)
+	}
+}
+
+// recordFunc records a click on the shortened url of the slug, made by a bot or not.
+type recordFunc func(c *fiber.Ctx, slug string, bot bool)
+
+// resolve resolves the shortened url of the slug, recording the click, and redirects to it.
+// Bots told apart by the classifier, if any, are redirected as well but their hits are counted apart,
+// except to the shortened urls having max hits, which they are forbidden.
+func resolve(c *fiber.Ctx, svc service.Service, password string, bots *service.BotClassifier, record recordFunc,
+	redirect func(*fiber.Ctx, models.URLShortened) error) error {
+	slug := c.Params("slug")
+	resolveFn := svc.Resolve
+	bot := bots.IsBot(c.Get(fiber.HeaderUserAgent))
+	if bot {
+		resolveFn = svc.ResolveBot
+	}
+	url, err := resolveFn(c.Context(), slug, password)
+	switch {
+	case errors.Is(err, service.ErrSlugNotFound):
+		return c.SendStatus(http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidSlug):
+		return c.SendStatus(http.StatusBadRequest)
+	case errors.Is(err, service.ErrURLExpired), errors.Is(err, service.ErrHitLimitReached):
+		return c.SendStatus(http.StatusGone)
+	case errors.Is(err, service.ErrURLBlocked):
+		// not gone, the destination may be unblocked later
+		return c.Status(http.StatusForbidden).SendString(err.Error())
+	case errors.Is(err, service.ErrBotForbidden):
+		return c.Status(http.StatusForbidden).SendString("this link can only be opened in a browser")
+	case errors.Is(err, service.ErrPasswordRequired):
+		return sendPasswordForm(c, http.StatusUnauthorized, "")
+	case errors.Is(err, service.ErrWrongPassword):
+		return sendPasswordForm(c, http.StatusUnauthorized, "Wrong password, please try again.")
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return sendPasswordForm(c, http.StatusTooManyRequests, "Too many attempts, please try again in a minute.")
+	case err != nil:
+		return c.Status(http.StatusInternalServerError).SendString(err.Error())
+	default: // all good
+		record(c, url.Slug, bot)
+		return redirect(c, url)
+	}
+}
+
+// recordClick records the click with the click recorder of the server, if any.
+func (srv HTTPServer) recordClick(c *fiber.Ctx, slug string, bot bool) {
+	if srv.clicks == nil {
+		return
+	}
+	v := visit(c, srv.countryHeader)
+	v.Bot = bot
+	srv.clicks.Record(slug, v)
+}
+
+// visit describes the request, copying the values out of the buffers fiber reuses once the handler returns,
+// since clicks are recorded asynchronously.
+// The country is read from the countryHeader, if any.
+func visit(c *fiber.Ctx, countryHeader string) service.Visit {
+	v := service.Visit{
+		IP:             utils.CopyString(c.IP()),
+		UserAgent:      utils.CopyString(c.Get(fiber.HeaderUserAgent)),
+		Referrer:       utils.CopyString(c.Get(fiber.HeaderReferer)),
+		AcceptLanguage: utils.CopyString(c.Get(fiber.HeaderAcceptLanguage)),
+		RequestID:      utils.CopyString(c.GetRespHeader(fiber.HeaderXRequestID)),
+	}
+	if countryHeader != "" {
+		v.Country = utils.CopyString(c.Get(countryHeader))
+	}
+	return v
+}
+
+// getStats returns the click statistics of the shortened url,
+// over the RFC 3339 from and to query parameters and bucketed by the interval one.
+func getStats(svc service.Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var query service.StatsQuery
+		err := parseTimes(c, map[string]*time.Time{"from": &query.From, "to": &query.To})
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		}
+		query.Interval = models.StatsInterval(c.Query("interval"))
+		stats, err := svc.Stats(c.Context(), c.Params("slug"), query)
 		switch {
 		case errors.Is(err, service.ErrSlugNotFound):
 			return c.SendStatus(http.StatusNotFound)
 		case errors.Is(err, service.ErrInvalidSlug):
 			return c.SendStatus(http.StatusBadRequest)
+		case errors.Is(err, service.ErrInvalidStatsQuery):
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
 		case err != nil:
 			return c.Status(http.StatusInternalServerError).SendString(err.Error())
 		default: // all good
-			return c.Redirect(url.URL, http.StatusMovedPermanently)
+			if err := c.Status(http.StatusOK).JSON(stats); err != nil {
+				return c.Status(http.StatusInternalServerError).SendString(err.Error())
+			}
 		}
+		return nil
 	}
 }
 
+// parseTimes parses the RFC 3339 query parameters into the times they are mapped to, skipping the missing ones.
+// Returns an error if any.
+func parseTimes(c *fiber.Ctx, params map[string]*time.Time) error {
+	for param, t := range params {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", param, err)
+		}
+		*t = parsed
+	}
+	return nil
+}
+
 func shortenURL(svc service.Service) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var url models.URLShortened
@@ -93,12 +220,20 @@ func shortenURL(svc service.Service) fiber.Handler {
 		if err != nil {
 			return c.SendStatus(http.StatusInternalServerError)
 		}
-		short, err := svc.Shorten(c.Context(), url.URL)
-		if err != nil {
-			return c.Status(http.StatusInternalServerError).SendString(err.Error())
-		}
-		if err := c.Status(http.StatusOK).JSON(short); err != nil {
+		short, err := svc.Shorten(c.Context(), url)
+		switch {
+		case errors.Is(err, service.ErrSlugReserved):
+			// every generated slug was reserved, nothing the client did wrong nor should know about
+			return c.Status(http.StatusServiceUnavailable).SendString("could not generate a slug, try again later")
+		case errors.Is(err, service.ErrInvalidURL), errors.Is(err, service.ErrURLBlocked), errors.Is(err, service.ErrInvalidExpiration),
+			errors.Is(err, service.ErrInvalidMaxHits), errors.Is(err, service.ErrInvalidRedirectStatus), errors.Is(err, service.ErrInvalidPassword):
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		case err != nil:
 			return c.Status(http.StatusInternalServerError).SendString(err.Error())
+		default: // all good
+			if err := c.Status(http.StatusOK).JSON(short); err != nil {
+				return c.Status(http.StatusInternalServerError).SendString(err.Error())
+			}
 		}
 		return nil
 	}
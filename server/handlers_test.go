@@ -8,13 +8,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
+	"strings"
 	"testing"
+	"time"
 
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang/mock/gomock"
 	"github.com/indiependente/pkg/logger"
 	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
 	"github.com/indiependente/shrtnr/service"
 	"github.com/stretchr/testify/require"
 )
@@ -45,6 +49,21 @@ func TestGetURL(t *testing.T) {
 				Hits: 1000,
 			},
 		},
+		{
+			name: "Happy path - password protected hides the url",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Get(gomock.Any(), "pizza").Return(models.URLShortened{
+					URL:          "http://pizza.com",
+					Slug:         "pizza",
+					PasswordHash: "hash",
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			want: models.URLShortened{
+				Slug: "pizza",
+			},
+		},
 		{
 			name:              "Sad path - empty slug",
 			slug:              "",
@@ -196,6 +215,53 @@ func TestPutURL(t *testing.T) {
 			wantStatus: http.StatusBadRequest,
 			want:       models.URLShortened{},
 		},
+		{
+			name: "Sad path - URL not valid",
+			url: models.URLShortened{
+				URL:  "http://127.0.0.1",
+				Slug: "pizza",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:  "http://127.0.0.1",
+					Slug: "pizza",
+				}).Return(models.URLShortened{}, service.ErrInvalidURL)
+			},
+			wantStatus: http.StatusBadRequest,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - Password not valid",
+			url: models.URLShortened{
+				URL:      "http://pizza.com",
+				Slug:     "pizza",
+				Password: "pizza",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:      "http://pizza.com",
+					Slug:     "pizza",
+					Password: "pizza",
+				}).Return(models.URLShortened{}, service.ErrInvalidPassword)
+			},
+			wantStatus: http.StatusBadRequest,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - Slug reserved",
+			url: models.URLShortened{
+				URL:  "http://pizza.com",
+				Slug: "admin",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:  "http://pizza.com",
+					Slug: "admin",
+				}).Return(models.URLShortened{}, service.ErrSlugReserved)
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       models.URLShortened{},
+		},
 		{
 			name: "Sad path - Unexpected error",
 			url: models.URLShortened{
@@ -371,3 +437,692 @@ func getPath(port int, path string, slug string) string {
 	}
 	return fmt.Sprintf("http://localhost:%d%s/%s", port, path, slug)
 }
+
+func TestResolveURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		slug              string
+		setupExpectations func(*service.MockService)
+		wantStatus        int
+		wantLocation      string
+		wantCacheControl  string
+	}{
+		{
+			name: "Happy path",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{
+					URL:  "http://pizza.com",
+					Slug: "pizza",
+				}, nil)
+			},
+			wantStatus:       http.StatusMovedPermanently,
+			wantLocation:     "http://pizza.com",
+			wantCacheControl: "public, max-age=86400",
+		},
+		{
+			name: "Happy path - redirect status of the link",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{
+					URL:            "http://pizza.com",
+					Slug:           "pizza",
+					RedirectStatus: http.StatusTemporaryRedirect,
+				}, nil)
+			},
+			wantStatus:       http.StatusTemporaryRedirect,
+			wantLocation:     "http://pizza.com",
+			wantCacheControl: "no-store",
+		},
+		{
+			name: "Sad path - Slug not found",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{}, service.ErrSlugNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Sad path - Expired",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{}, service.ErrURLExpired)
+			},
+			wantStatus: http.StatusGone,
+		},
+		{
+			name: "Sad path - Blocked",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{}, service.ErrURLBlocked)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "Sad path - Hit limit reached",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{}, service.ErrHitLimitReached)
+			},
+			wantStatus: http.StatusGone,
+		},
+		{
+			name: "Sad path - Password required",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{}, service.ErrPasswordRequired)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "Sad path - Unexpected error",
+			slug: "pizza",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{}, errors.New("unexpected error"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockSvc := service.NewMockService(ctrl)
+			tt.setupExpectations(mockSvc)
+
+			port := 12348
+			app := fiber.New(fiber.Config{
+				CaseSensitive:    true,
+				StrictRouting:    true,
+				ServerHeader:     "Fiber",
+				DisableKeepalive: true, // this is needed to avoid the shutdown being stuck for 30-60 seconds
+			})
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(app, mockSvc, port, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED))
+			require.NoError(t, err)
+			err = srv.Setup(ctx)
+			require.NoError(t, err)
+			defer srv.Shutdown(ctx) // nolint: errcheck
+			// Start HTTP server
+			go func() {
+				err := srv.Start(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+
+			// build request
+			path := getPath(port, URLResolvePath, tt.slug)
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			// send request to server, without following redirects
+			client := &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			// check status code and redirect location
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			require.Equal(t, tt.wantLocation, resp.Header.Get("Location"))
+			if tt.wantCacheControl != "" {
+				require.Equal(t, tt.wantCacheControl, resp.Header.Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestUnlockURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		slug              string
+		password          string
+		setupExpectations func(*service.MockService)
+		wantStatus        int
+		wantLocation      string
+	}{
+		{
+			name:     "Happy path",
+			slug:     "pizza",
+			password: "margherita",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "margherita").Return(models.URLShortened{
+					URL:  "http://pizza.com",
+					Slug: "pizza",
+				}, nil)
+			},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "http://pizza.com",
+		},
+		{
+			name:     "Sad path - Wrong password",
+			slug:     "pizza",
+			password: "marinara",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "marinara").Return(models.URLShortened{}, service.ErrWrongPassword)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "Sad path - Too many attempts",
+			slug:     "pizza",
+			password: "marinara",
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Resolve(gomock.Any(), "pizza", "marinara").Return(models.URLShortened{}, service.ErrTooManyAttempts)
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockSvc := service.NewMockService(ctrl)
+			tt.setupExpectations(mockSvc)
+
+			port := 12350
+			app := fiber.New(fiber.Config{
+				CaseSensitive:    true,
+				StrictRouting:    true,
+				ServerHeader:     "Fiber",
+				DisableKeepalive: true, // this is needed to avoid the shutdown being stuck for 30-60 seconds
+			})
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(app, mockSvc, port, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED))
+			require.NoError(t, err)
+			err = srv.Setup(ctx)
+			require.NoError(t, err)
+			defer srv.Shutdown(ctx) // nolint: errcheck
+			// Start HTTP server
+			go func() {
+				err := srv.Start(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+
+			// build request
+			path := getPath(port, URLResolvePath, tt.slug)
+			form := neturl.Values{"password": {tt.password}}
+			req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			// send request to server, without following redirects
+			client := &http.Client{
+				CheckRedirect: func(*http.Request, []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			// check status code and redirect location
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			require.Equal(t, tt.wantLocation, resp.Header.Get("Location"))
+		})
+	}
+}
+
+func TestShortenURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name              string
+		url               models.URLShortened
+		setupExpectations func(*service.MockService)
+		wantStatus        int
+		want              models.URLShortened
+	}{
+		{
+			name: "Happy path",
+			url: models.URLShortened{
+				URL: "http://pizza.com",
+				TTL: 3600,
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL: "http://pizza.com",
+					TTL: 3600,
+				}).Return(models.URLShortened{
+					URL:  "http://pizza.com",
+					Slug: "pizza",
+				}, nil)
+			},
+			wantStatus: http.StatusOK,
+			want: models.URLShortened{
+				URL:  "http://pizza.com",
+				Slug: "pizza",
+			},
+		},
+		{
+			name: "Sad path - Expiration not valid",
+			url: models.URLShortened{
+				URL: "http://pizza.com",
+				TTL: -1,
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL: "http://pizza.com",
+					TTL: -1,
+				}).Return(models.URLShortened{}, service.ErrInvalidExpiration)
+			},
+			wantStatus: http.StatusBadRequest,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - URL not valid",
+			url: models.URLShortened{
+				URL: "javascript:alert(1)",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL: "javascript:alert(1)",
+				}).Return(models.URLShortened{}, service.ErrInvalidURL)
+			},
+			wantStatus: http.StatusBadRequest,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - URL blocked",
+			url: models.URLShortened{
+				URL: "http://malware.example",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL: "http://malware.example",
+				}).Return(models.URLShortened{}, service.ErrURLBlocked)
+			},
+			wantStatus: http.StatusBadRequest,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - Password not valid",
+			url: models.URLShortened{
+				URL:      "http://pizza.com",
+				Password: "pizza",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL:      "http://pizza.com",
+					Password: "pizza",
+				}).Return(models.URLShortened{}, service.ErrInvalidPassword)
+			},
+			wantStatus: http.StatusBadRequest,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - Generated slugs reserved",
+			url: models.URLShortened{
+				URL: "http://pizza.com",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL: "http://pizza.com",
+				}).Return(models.URLShortened{}, fmt.Errorf("could not add after 5 attempts: %w", service.ErrSlugReserved))
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			want:       models.URLShortened{},
+		},
+		{
+			name: "Sad path - Unexpected error",
+			url: models.URLShortened{
+				URL: "http://pizza.com",
+			},
+			setupExpectations: func(mockService *service.MockService) {
+				mockService.EXPECT().Shorten(gomock.Any(), models.URLShortened{
+					URL: "http://pizza.com",
+				}).Return(models.URLShortened{}, errors.New("unexpected error"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			want:       models.URLShortened{},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockSvc := service.NewMockService(ctrl)
+			tt.setupExpectations(mockSvc)
+
+			port := 12349
+			app := fiber.New(fiber.Config{
+				CaseSensitive:    true,
+				StrictRouting:    true,
+				ServerHeader:     "Fiber",
+				DisableKeepalive: true, // this is needed to avoid the shutdown being stuck for 30-60 seconds
+			})
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(app, mockSvc, port, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED))
+			require.NoError(t, err)
+			err = srv.Setup(ctx)
+			require.NoError(t, err)
+			defer srv.Shutdown(ctx) // nolint: errcheck
+			// Start HTTP server
+			go func() {
+				err := srv.Start(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+
+			// build request
+			path := getPath(port, URLShortenPath, "")
+			reqBody, err := json.Marshal(tt.url)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(reqBody))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			// send request to server
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			// check status code
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			// parse and check response body
+			url := models.URLShortened{}
+			data, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			err = json.Unmarshal(data, &url)
+			if err != nil {
+				return
+			}
+			require.Equal(t, tt.want, url)
+		})
+	}
+}
+
+func TestResolveURLRecordsClick(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSvc := service.NewMockService(ctrl)
+	mockSvc.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(models.URLShortened{
+		URL:  "http://pizza.com",
+		Slug: "pizza",
+	}, nil)
+	mockSvc.EXPECT().Resolve(gomock.Any(), "pasta", "").Return(models.URLShortened{}, service.ErrSlugNotFound)
+	mockClicks := service.NewMockClickRecorder(ctrl)
+	mockClicks.EXPECT().Record("pizza", gomock.Any()).Do(func(_ string, visit service.Visit) {
+		require.Equal(t, "Mozilla/5.0", visit.UserAgent)
+		require.Equal(t, "https://news.example", visit.Referrer)
+		require.Equal(t, "it-IT", visit.AcceptLanguage)
+		require.NotEmpty(t, visit.IP)
+		require.NotEmpty(t, visit.RequestID)
+		require.Equal(t, "IT", visit.Country)
+	})
+
+	app := fiber.New(fiber.Config{
+		CaseSensitive:    true,
+		StrictRouting:    true,
+		ServerHeader:     "Fiber",
+		DisableKeepalive: true, // this is needed to avoid the shutdown being stuck for 30-60 seconds
+	})
+	box, err := rice.FindBox(".")
+	require.NoError(t, err)
+	srv, err := NewHTTPServer(app, mockSvc, 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED),
+		WithClickRecorder(mockClicks),
+		WithCountryHeader("CF-IPCountry"),
+	)
+	require.NoError(t, err)
+	err = srv.Setup(ctx)
+	require.NoError(t, err)
+
+	// send requests to the app, without listening
+	for slug, wantStatus := range map[string]int{"pizza": http.StatusMovedPermanently, "pasta": http.StatusNotFound} {
+		req, err := http.NewRequest(http.MethodGet, URLResolvePath+"/"+slug, nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", "Mozilla/5.0")
+		req.Header.Set("Referer", "https://news.example")
+		req.Header.Set("Accept-Language", "it-IT")
+		req.Header.Set("CF-IPCountry", "IT")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close() // nolint: errcheck
+		require.Equal(t, wantStatus, resp.StatusCode)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	from := time.Date(2020, time.October, 12, 0, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	stats := models.ClickStats{
+		Slug:     "pizza",
+		From:     from,
+		To:       to,
+		Interval: models.IntervalHour,
+		Total:    3,
+		Buckets: []models.ClickBucket{
+			{Start: from, Count: 1},
+			{Start: from.Add(time.Hour), Count: 2},
+		},
+		Referrers:  []models.Tally{{Value: "https://news.example", Count: 3}},
+		UserAgents: []models.Tally{},
+		Browsers:   []models.Tally{{Value: "Firefox", Count: 3}},
+		Countries:  []models.Tally{},
+	}
+
+	tests := []struct {
+		name       string
+		slug       string
+		query      string
+		setupExpec func(*service.MockService)
+		wantStatus int
+		want       *models.ClickStats
+	}{
+		{
+			name:  "Happy Path",
+			slug:  "pizza",
+			query: "?from=2020-10-12T00:00:00Z&to=2020-10-12T02:00:00Z&interval=hour",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().Stats(gomock.Any(), "pizza", service.StatsQuery{
+					From:     from,
+					To:       to,
+					Interval: models.IntervalHour,
+				}).Return(stats, nil)
+			},
+			wantStatus: http.StatusOK,
+			want:       &stats,
+		},
+		{
+			name:  "Happy Path - defaults",
+			slug:  "pizza",
+			query: "",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().Stats(gomock.Any(), "pizza", service.StatsQuery{}).Return(stats, nil)
+			},
+			wantStatus: http.StatusOK,
+			want:       &stats,
+		},
+		{
+			name:       "Sad Path - from not valid",
+			slug:       "pizza",
+			query:      "?from=yesterday",
+			setupExpec: func(svc *service.MockService) {},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Sad Path - query not valid",
+			slug:  "pizza",
+			query: "?interval=minute",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().Stats(gomock.Any(), "pizza", service.StatsQuery{Interval: "minute"}).
+					Return(models.ClickStats{}, service.ErrInvalidStatsQuery)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "Sad Path - slug not found",
+			slug:  "pasta",
+			query: "",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().Stats(gomock.Any(), "pasta", service.StatsQuery{}).
+					Return(models.ClickStats{}, service.ErrSlugNotFound)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:  "Sad Path - internal error",
+			slug:  "pizza",
+			query: "",
+			setupExpec: func(svc *service.MockService) {
+				svc.EXPECT().Stats(gomock.Any(), "pizza", service.StatsQuery{}).
+					Return(models.ClickStats{}, errors.New("boom"))
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockSvc := service.NewMockService(ctrl)
+			tt.setupExpec(mockSvc)
+
+			app := fiber.New(fiber.Config{
+				CaseSensitive: true,
+				StrictRouting: true,
+				ServerHeader:  "Fiber",
+			})
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(app, mockSvc, 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED))
+			require.NoError(t, err)
+			err = srv.Setup(ctx)
+			require.NoError(t, err)
+
+			// send the request to the app, without listening
+			req, err := http.NewRequest(http.MethodGet, URLShortenPath+"/"+tt.slug+"/stats"+tt.query, nil)
+			require.NoError(t, err)
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			defer resp.Body.Close() // nolint: errcheck
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.want == nil {
+				return
+			}
+			var got models.ClickStats
+			err = json.NewDecoder(resp.Body).Decode(&got)
+			require.NoError(t, err)
+			require.Equal(t, *tt.want, got)
+		})
+	}
+}
+
+func TestResolveURLBots(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSvc := service.NewMockService(ctrl)
+	pizza := models.URLShortened{
+		URL:  "http://pizza.com",
+		Slug: "pizza",
+	}
+	mockSvc.EXPECT().Resolve(gomock.Any(), "pizza", "").Return(pizza, nil)
+	mockSvc.EXPECT().ResolveBot(gomock.Any(), "pizza", "").Return(pizza, nil).Times(2)
+	mockClicks := service.NewMockClickRecorder(ctrl)
+	var bots []bool
+	mockClicks.EXPECT().Record("pizza", gomock.Any()).Do(func(_ string, visit service.Visit) {
+		bots = append(bots, visit.Bot)
+	}).Times(3)
+
+	app := fiber.New(fiber.Config{
+		CaseSensitive:    true,
+		StrictRouting:    true,
+		ServerHeader:     "Fiber",
+		DisableKeepalive: true, // this is needed to avoid the shutdown being stuck for 30-60 seconds
+	})
+	box, err := rice.FindBox(".")
+	require.NoError(t, err)
+	srv, err := NewHTTPServer(app, mockSvc, 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED),
+		WithClickRecorder(mockClicks),
+		WithBotClassifier(service.NewBotClassifier(service.DefaultBotRules)),
+	)
+	require.NoError(t, err)
+	err = srv.Setup(ctx)
+	require.NoError(t, err)
+
+	// send requests to the app, without listening, bots are redirected as well
+	for _, userAgent := range []string{
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"",
+	} {
+		req, err := http.NewRequest(http.MethodGet, URLResolvePath+"/pizza", nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close() // nolint: errcheck
+		require.Equal(t, http.StatusMovedPermanently, resp.StatusCode, userAgent)
+	}
+	require.Equal(t, []bool{false, true, true}, bots)
+}
+
+func TestResolveURLBotsLimited(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := repository.NewMemoryURLStorer()
+	require.NoError(t, store.Add(ctx, models.URLShortened{URL: "http://pizza.com", Slug: "pizza", MaxHits: 1}))
+	svc := service.NewURLService(store, service.NewFixedLenSlugger(5), service.NewMockHitCounter(ctrl))
+
+	app := fiber.New(fiber.Config{
+		CaseSensitive:    true,
+		StrictRouting:    true,
+		ServerHeader:     "Fiber",
+		DisableKeepalive: true, // this is needed to avoid the shutdown being stuck for 30-60 seconds
+	})
+	box, err := rice.FindBox(".")
+	require.NoError(t, err)
+	srv, err := NewHTTPServer(app, svc, 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED),
+		WithBotClassifier(service.NewBotClassifier(service.DefaultBotRules)),
+	)
+	require.NoError(t, err)
+	err = srv.Setup(ctx)
+	require.NoError(t, err)
+
+	// send requests to the app, without listening, bots never get to the original url of a limited link
+	browser := "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	for _, tt := range []struct {
+		userAgent  string
+		wantStatus int
+	}{
+		{userAgent: "", wantStatus: http.StatusForbidden},
+		{userAgent: "curl/8.4.0", wantStatus: http.StatusForbidden},
+		{userAgent: "", wantStatus: http.StatusForbidden},
+		{userAgent: "curl/8.4.0", wantStatus: http.StatusForbidden},
+		{userAgent: browser, wantStatus: http.StatusMovedPermanently},
+		{userAgent: browser, wantStatus: http.StatusGone},
+		{userAgent: "curl/8.4.0", wantStatus: http.StatusGone},
+	} {
+		req, err := http.NewRequest(http.MethodGet, URLResolvePath+"/pizza", nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", tt.userAgent)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		resp.Body.Close() // nolint: errcheck
+		require.Equal(t, tt.wantStatus, resp.StatusCode, tt.userAgent)
+		if tt.wantStatus != http.StatusMovedPermanently {
+			require.Empty(t, resp.Header.Get("Location"), tt.userAgent)
+		}
+	}
+}
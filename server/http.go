@@ -2,14 +2,25 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/indiependente/shrtnr/models"
 	"github.com/indiependente/shrtnr/service"
 )
 
+const (
+	// shutdownTimeout is the time the shutdown hooks have to complete.
+	shutdownTimeout = 10 * time.Second
+	// defaultExportTimeout is the time an export of clicks has to complete, unless set with WithExportTimeout.
+	defaultExportTimeout = 10 * time.Minute
+)
+
 // HTTPServer implements a Server capable of serving HTTP requests.
 type HTTPServer struct {
 	app    *fiber.App
@@ -17,17 +28,97 @@ type HTTPServer struct {
 	port   int
 	log    logger.Logger
 	assets http.FileSystem
+	hooks  []shutdown.TerminationFn
+
+	redirectStatus int
+	clicks         service.ClickRecorder
+	countryHeader  string
+	bots           *service.BotClassifier
+	exportTimeout  time.Duration
+
+	// base is the context of the work outliving the handlers, e.g. streaming exports, cancelled by Shutdown.
+	base   context.Context
+	cancel context.CancelFunc
+}
+
+// Option configures an optional setting of the HTTPServer.
+type Option func(*HTTPServer)
+
+// WithShutdownHooks registers functions run by Shutdown once the server stopped serving requests,
+// e.g. to drain buffered writes.
+func WithShutdownHooks(hooks ...shutdown.TerminationFn) Option {
+	return func(srv *HTTPServer) {
+		srv.hooks = append(srv.hooks, hooks...)
+	}
+}
+
+// WithRedirectStatus sets the HTTP status used to redirect to original urls,
+// unless overridden by the shortened url. It defaults to 301 Moved Permanently.
+func WithRedirectStatus(status int) Option {
+	return func(srv *HTTPServer) {
+		srv.redirectStatus = status
+	}
+}
+
+// WithClickRecorder records a click every time a shortened url is resolved.
+func WithClickRecorder(clicks service.ClickRecorder) Option {
+	return func(srv *HTTPServer) {
+		srv.clicks = clicks
+	}
+}
+
+// WithCountryHeader reads the country of the visitors recording clicks from the request header,
+// e.g. CF-IPCountry, set by a trusted proxy in front of the server.
+func WithCountryHeader(header string) Option {
+	return func(srv *HTTPServer) {
+		srv.countryHeader = header
+	}
+}
+
+// WithBotClassifier tells bots and crawlers apart from people resolving shortened urls,
+// they are still redirected but their hits are counted apart and never consume the max hits.
+func WithBotClassifier(bots *service.BotClassifier) Option {
+	return func(srv *HTTPServer) {
+		srv.bots = bots
+	}
+}
+
+// WithExportTimeout sets the time an export of clicks has to complete, before being cut short.
+// It defaults to 10 minutes.
+func WithExportTimeout(timeout time.Duration) Option {
+	return func(srv *HTTPServer) {
+		srv.exportTimeout = timeout
+	}
 }
 
 // NewHTTPServer returns a new instance of an HTTPServer.
-func NewHTTPServer(app *fiber.App, svc service.Service, port int, assets http.FileSystem, log logger.Logger) (HTTPServer, error) {
-	return HTTPServer{
+func NewHTTPServer(app *fiber.App, svc service.Service, port int, assets http.FileSystem, log logger.Logger, opts ...Option) (HTTPServer, error) {
+	srv := HTTPServer{
 		app:    app,
 		svc:    svc,
 		port:   port,
 		log:    log,
 		assets: assets,
-	}, nil
+
+		redirectStatus: http.StatusMovedPermanently,
+		exportTimeout:  defaultExportTimeout,
+	}
+	for _, opt := range opts {
+		opt(&srv)
+	}
+	if !models.ValidRedirectStatus(srv.redirectStatus) {
+		return HTTPServer{}, fmt.Errorf("redirect status %d not valid", srv.redirectStatus)
+	}
+	if srv.exportTimeout <= 0 {
+		return HTTPServer{}, fmt.Errorf("export timeout %s not positive", srv.exportTimeout)
+	}
+	srv.base, srv.cancel = context.WithCancel(context.Background())
+	return srv, nil
+}
+
+// exportContext returns the context of an export of clicks, cancelled after the export timeout or by Shutdown.
+func (srv HTTPServer) exportContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(srv.base, srv.exportTimeout)
 }
 
 // Start starts the HTTP server.
@@ -35,9 +126,26 @@ func (srv HTTPServer) Start(ctx context.Context) error {
 	return srv.app.Listen(fmt.Sprintf(":%d", srv.port))
 }
 
-// Shutdown stops the HTTP server.
+// Shutdown stops the HTTP server and then runs the shutdown hooks.
+// Exports still streaming are cut short first, rather than holding up the shutdown.
+// The hooks get a fresh context, since the input one is usually already cancelled on shutdown.
+// The hooks run even if the app could not be stopped cleanly or an earlier hook failed, so that buffered writes are not lost.
+// Returns all the errors joined, if any.
 func (srv HTTPServer) Shutdown(ctx context.Context) error {
-	return srv.app.Shutdown()
+	srv.cancel()
+	var errs []error
+	if err := srv.app.Shutdown(); err != nil {
+		errs = append(errs, fmt.Errorf("could not shutdown app: %w", err))
+	}
+	hooksCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, hook := range srv.hooks {
+		err := hook(hooksCtx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not run shutdown hook: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Setup applies all the server configurations enabling startup.
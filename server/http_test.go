@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/pkg/logger"
+	"github.com/indiependente/pkg/shutdown"
+	"github.com/indiependente/shrtnr/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer_Shutdown(t *testing.T) {
+	t.Parallel()
+	errHits := errors.New("hits not flushed")
+	errClicks := errors.New("clicks not flushed")
+
+	tests := []struct {
+		name     string
+		hooks    []error
+		wantErrs []error
+	}{
+		{
+			name:  "Happy Path",
+			hooks: []error{nil, nil},
+		},
+		{
+			name:     "Sad Path - first hook failed",
+			hooks:    []error{errHits, nil},
+			wantErrs: []error{errHits},
+		},
+		{
+			name:     "Sad Path - every hook failed",
+			hooks:    []error{errHits, errClicks},
+			wantErrs: []error{errHits, errClicks},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ran := make([]bool, len(tt.hooks))
+			hooks := make([]shutdown.TerminationFn, len(tt.hooks))
+			for i, err := range tt.hooks {
+				i, err := i, err
+				hooks[i] = func(context.Context) error {
+					ran[i] = true
+					return err
+				}
+			}
+			box, err := rice.FindBox(".")
+			require.NoError(t, err)
+			srv, err := NewHTTPServer(fiber.New(), service.NewMockService(ctrl), 0, box.HTTPBox(), logger.GetLogger("test", logger.DISABLED),
+				WithShutdownHooks(hooks...))
+			require.NoError(t, err)
+
+			err = srv.Shutdown(context.Background())
+			// every hook runs, so that a failed one does not lose the writes buffered by the next ones
+			for i := range ran {
+				require.True(t, ran[i], "hook %d did not run", i)
+			}
+			if len(tt.wantErrs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			for _, wantErr := range tt.wantErrs {
+				require.ErrorIs(t, err, wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// passwordForm is the page asking for the password of a protected shortened url.
+// It posts the password back to the same path it is served from.
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>shrtnr - password required</title>
+<style>
+body { font-family: sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+form { display: flex; flex-direction: column; gap: .5em; min-width: 18em; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+<label for="password">This link is password protected.</label>
+<input type="password" id="password" name="password" autocomplete="current-password" autofocus required>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+type passwordPage struct {
+	Action string
+	Error  string
+}
+
+// sendPasswordForm renders the password form with the input status and error message, if any.
+func sendPasswordForm(c *fiber.Ctx, status int, errMsg string) error {
+	var buf bytes.Buffer
+	err := passwordForm.Execute(&buf, passwordPage{
+		Action: c.Path(),
+		Error:  errMsg,
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).SendString(err.Error())
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Type("html", "utf-8")
+	return c.Status(status).Send(buf.Bytes())
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/indiependente/shrtnr/models"
+)
+
+const (
+	// permanentRedirectMaxAge is the longest time browsers and proxies are allowed to cache a permanent redirect,
+	// bounding how long an edited or deleted shortened url keeps redirecting to the old original url.
+	permanentRedirectMaxAge = 24 * time.Hour
+)
+
+// redirect redirects to the original url using the redirect status of the shortened url,
+// falling back to the server wide one, along with the caching headers matching the status.
+func redirect(c *fiber.Ctx, url models.URLShortened, defaultStatus int) error {
+	status := url.RedirectStatus
+	if status == 0 {
+		status = defaultStatus
+	}
+	c.Set(fiber.HeaderCacheControl, cacheControl(url, status, time.Now()))
+	return c.Redirect(url.URL, status)
+}
+
+// seeOther redirects to the original url after a form submission, the redirect is never cached.
+func seeOther(c *fiber.Ctx, url models.URLShortened) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Redirect(url.URL, http.StatusSeeOther)
+}
+
+// cacheControl returns the Cache-Control header value for redirecting to the shortened url with the status.
+// Permanent redirects are cached for a while, but never past the expiration time of the shortened url.
+// Temporary redirects and redirects to shortened urls with max hits are never cached, so that every hit reaches the server.
+func cacheControl(url models.URLShortened, status int, now time.Time) string {
+	if url.MaxHits > 0 || url.Protected() {
+		return "no-store"
+	}
+	switch status {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		maxAge := permanentRedirectMaxAge
+		if url.ExpiresAt != nil && url.ExpiresAt.Sub(now) < maxAge {
+			maxAge = url.ExpiresAt.Sub(now)
+		}
+		return fmt.Sprintf("public, max-age=%d", int64(maxAge/time.Second))
+	default:
+		return "no-store"
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheControl(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, time.October, 18, 12, 0, 0, 0, time.UTC)
+	inAnHour := now.Add(time.Hour)
+	inAWeek := now.Add(7 * 24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		url    models.URLShortened
+		status int
+		want   string
+	}{
+		{
+			name:   "Moved permanently",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza"},
+			status: http.StatusMovedPermanently,
+			want:   "public, max-age=86400",
+		},
+		{
+			name:   "Permanent redirect",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza"},
+			status: http.StatusPermanentRedirect,
+			want:   "public, max-age=86400",
+		},
+		{
+			name:   "Permanent redirect - expiring soon",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza", ExpiresAt: &inAnHour},
+			status: http.StatusMovedPermanently,
+			want:   "public, max-age=3600",
+		},
+		{
+			name:   "Permanent redirect - expiring later",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza", ExpiresAt: &inAWeek},
+			status: http.StatusMovedPermanently,
+			want:   "public, max-age=86400",
+		},
+		{
+			name:   "Permanent redirect - max hits",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza", MaxHits: 10},
+			status: http.StatusMovedPermanently,
+			want:   "no-store",
+		},
+		{
+			name:   "Found",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza"},
+			status: http.StatusFound,
+			want:   "no-store",
+		},
+		{
+			name:   "Temporary redirect",
+			url:    models.URLShortened{URL: "http://pizza.com", Slug: "pizza"},
+			status: http.StatusTemporaryRedirect,
+			want:   "no-store",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, cacheControl(tt.url, tt.status, now))
+		})
+	}
+}
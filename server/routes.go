@@ -1,11 +1,14 @@
 package server
 
 import (
+	"bytes"
 	"os"
 	"time"
 
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/expvar"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/pprof"
@@ -25,6 +28,7 @@ func (srv HTTPServer) middlewares() {
 	srv.app.Use(compress.New())
 	srv.app.Use(recover.New())
 	srv.app.Use(pprof.New())
+	srv.app.Use(expvar.New())
 	srv.app.Use(requestid.New(requestid.Config{
 		Generator: func() string {
 			return uuid.New().String()
@@ -40,6 +44,9 @@ func (srv HTTPServer) middlewares() {
 			" \"ip\": \"${ip}\", \"host\": \"${host}\", \"method\": \"${method}\", \"header\":\"${header:x-request-id}\"," +
 			"\"url\": \"${url}\", \"ua\": \"${ua}\", \"latency\": \"${latency}\", \"status\": \"${status}\", \"body\": \"${body}\", " +
 			"\"bytesSent\": \"${bytesSent}\", \"bytesReceived\": \"${bytesReceived}\", \"route\": \"${route}\", \"error\": \"${error}\"}\n",
+		CustomTags: map[string]logger.LogFunc{
+			logger.TagBody: redactedBody,
+		},
 		TimeFormat: time.RFC3339Nano,
 		TimeZone:   "Local",
 		Output:     os.Stdout,
@@ -47,10 +54,22 @@ func (srv HTTPServer) middlewares() {
 
 }
 
+// redactedBody logs the request body, unless it carries a password.
+func redactedBody(output logger.Buffer, c *fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+	if bytes.Contains(c.Body(), []byte("password")) {
+		return output.WriteString("[redacted]")
+	}
+	return output.Write(c.Body())
+}
+
 func (srv HTTPServer) routes() {
 	srv.app.Get(URLShortenPath+"/:slug", getURL(srv.svc))
+	srv.app.Get(URLShortenPath+"/:slug/stats", getStats(srv.svc))
+	srv.app.Get(URLShortenPath+"/:slug/clicks/export", exportClicks(srv.svc, srv.log, srv.exportContext))
+	srv.app.Get(URLShortenPath+"/clicks/export", exportAllClicks(srv.svc, srv.log, srv.exportContext))
 	srv.app.Put(URLShortenPath, putURL(srv.svc))
 	srv.app.Delete(URLShortenPath+"/:slug", delURL(srv.svc))
-	srv.app.Get(URLResolvePath+"/:slug", resolveURL(srv.svc))
+	srv.app.Get(URLResolvePath+"/:slug", resolveURL(srv.svc, srv.redirectStatus, srv.bots, srv.recordClick))
+	srv.app.Post(URLResolvePath+"/:slug", unlockURL(srv.svc, srv.bots, srv.recordClick))
 	srv.app.Post(URLShortenPath, shortenURL(srv.svc))
 }
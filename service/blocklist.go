@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+)
+
+// Blocklist is a list of the destinations shortened urls must not redirect to, such as malware or phishing sites,
+// loaded from a file and reloaded whenever the file changes.
+// The file lists one entry per line, either plainly or in the hosts file format, an address followed by the entries:
+//   - a domain, e.g. evil.example, blocks the urls of that host only
+//   - a wildcard domain, e.g. *.evil.example, blocks the urls of that host and of all its subdomains
+//   - a url, e.g. example.com/phishing, blocks that path and the ones below it on the host, whatever the scheme and the query
+//
+// Blank lines and anything following a # are skipped.
+// The nil value blocks nothing. It is safe for concurrent use.
+type Blocklist struct {
+	path     string
+	interval time.Duration
+	log      logger.Logger
+
+	mu       sync.RWMutex
+	entries  blocklistEntries
+	modTime  time.Time
+	size     int64
+	loadedAt time.Time
+	err      error // of the last reload, if it failed
+}
+
+// BlocklistStats are the statistics of a Blocklist.
+type BlocklistStats struct {
+	Entries  int       `json:"entries"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// Error is the reason the last reload failed, if it did, in which case the blocklist loaded at LoadedAt is still in force.
+	Error string `json:"error,omitempty"`
+}
+
+// blocklistEntries are the parsed entries of a blocklist.
+type blocklistEntries struct {
+	hosts     map[string]bool
+	wildcards map[string]bool
+	paths     map[string]bool // host followed by path
+}
+
+// NewBlocklist returns a new Blocklist loading the file at path, checked for changes every interval once started,
+// logging the reloads that failed.
+// Returns an error if the file can not be loaded.
+func NewBlocklist(path string, interval time.Duration, log logger.Logger) (*Blocklist, error) {
+	b := &Blocklist{
+		path:     path,
+		interval: interval,
+		log:      log,
+	}
+	_, err := b.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Start reloads the blocklist whenever its file changes until the context is cancelled.
+// The blocklist is kept as it is if the file can not be loaded, until it is fixed, and the failure is logged
+// at every check in the meantime. It blocks until then.
+func (b *Blocklist) Start(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		_, err := b.Reload()
+		if err != nil {
+			b.log.Error("could not reload blocklist, keeping the one loaded", err)
+		}
+	}
+}
+
+// Reload loads the file of the blocklist again if it changed since the last load, reporting whether it did.
+// The error, if any, is kept for Stats until a later reload succeeds.
+// Returns an error if any.
+func (b *Blocklist) Reload() (bool, error) {
+	reloaded, err := b.reload()
+	b.mu.Lock()
+	b.err = err
+	b.mu.Unlock()
+	return reloaded, err
+}
+
+// reload loads the file of the blocklist again if it changed since the last load, reporting whether it did.
+// Returns an error if any.
+func (b *Blocklist) reload() (bool, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, fmt.Errorf("could not stat blocklist: %w", err)
+	}
+	b.mu.RLock()
+	unchanged := info.ModTime().Equal(b.modTime) && info.Size() == b.size
+	b.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	f, err := os.Open(b.path)
+	if err != nil {
+		return false, fmt.Errorf("could not open blocklist: %w", err)
+	}
+	defer f.Close() // nolint: errcheck
+	entries, err := parseBlocklist(f)
+	if err != nil {
+		return false, err
+	}
+	b.mu.Lock()
+	b.entries, b.modTime, b.size = entries, info.ModTime(), info.Size()
+	b.loadedAt = time.Now()
+	b.mu.Unlock()
+	return true, nil
+}
+
+// Stats returns the statistics of the blocklist.
+func (b *Blocklist) Stats() BlocklistStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	stats := BlocklistStats{
+		Entries:  len(b.entries.hosts) + len(b.entries.wildcards) + len(b.entries.paths),
+		LoadedAt: b.loadedAt,
+	}
+	if b.err != nil {
+		stats.Error = b.err.Error()
+	}
+	return stats
+}
+
+// Blocked reports whether the url is blocked.
+// The url is normalized again before being matched, so that urls stored before the rules of the canonical form changed
+// are matched as well, and empty path segments are skipped, since many servers read e.g. //phishing as /phishing.
+func (b *Blocklist) Blocked(canonicalURL string) bool {
+	if b == nil {
+		return false
+	}
+	canonicalURL, err := URLNormalizer{}.Normalize(canonicalURL)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(canonicalURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.entries.hosts[host] {
+		return true
+	}
+	for domain := host; domain != ""; domain = parentDomain(domain) {
+		if b.entries.wildcards[domain] {
+			return true
+		}
+	}
+	p := blocklistPath(u)
+	for p != "" {
+		if b.entries.paths[host+p] {
+			return true
+		}
+		i := strings.LastIndexByte(p, '/')
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return false
+}
+
+// parentDomain returns the domain without its first label, or an empty string if it has a single label.
+func parentDomain(domain string) string {
+	i := strings.IndexByte(domain, '.')
+	if i < 0 {
+		return ""
+	}
+	return domain[i+1:]
+}
+
+// parseBlocklist parses the entries of a blocklist.
+// Returns an error if any.
+func parseBlocklist(r io.Reader) (blocklistEntries, error) {
+	entries := blocklistEntries{
+		hosts:     map[string]bool{},
+		wildcards: map[string]bool{},
+		paths:     map[string]bool{},
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+			fields = fields[1:] // hosts file format
+		}
+		for _, field := range fields {
+			entries.add(field)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return blocklistEntries{}, fmt.Errorf("could not read blocklist: %w", err)
+	}
+	return entries, nil
+}
+
+// add adds the entry, skipping it if it is not valid.
+func (e blocklistEntries) add(entry string) {
+	if strings.HasPrefix(entry, "*.") {
+		if host, ok := blocklistHost(entry[2:]); ok {
+			e.wildcards[host] = true
+		}
+		return
+	}
+	if !strings.Contains(entry, "/") {
+		if host, ok := blocklistHost(entry); ok {
+			e.hosts[host] = true
+		}
+		return
+	}
+	canonical, err := URLNormalizer{}.Normalize(entry)
+	if err != nil {
+		return
+	}
+	u, err := url.Parse(canonical)
+	if err != nil || u.Hostname() == "" {
+		return
+	}
+	if p := blocklistPath(u); p != "" {
+		e.paths[u.Hostname()+p] = true
+		return
+	}
+	e.hosts[u.Hostname()] = true
+}
+
+// blocklistPath returns the escaped path of the url without empty segments, e.g. /a/b for //a/b/.
+func blocklistPath(u *url.URL) string {
+	var b strings.Builder
+	for _, segment := range strings.Split(u.EscapedPath(), "/") {
+		if segment != "" {
+			b.WriteByte('/')
+			b.WriteString(segment)
+		}
+	}
+	return b.String()
+}
+
+// blocklistHost returns the domain in the form of the hosts of canonical urls, reporting whether it is valid.
+func blocklistHost(domain string) (string, bool) {
+	host, err := toASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || host == "" {
+		return "", false
+	}
+	return host, true
+}
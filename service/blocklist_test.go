@@ -0,0 +1,204 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indiependente/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const testBlocklist = `# malware and phishing
+0.0.0.0 malware.example tracker.example # hosts file format
+127.0.0.1	Bücher.example
+*.evil.example
+phishing.example/login
+https://example.com/phish/
+example.net/
+`
+
+func TestBlocklist_Blocked(t *testing.T) {
+	t.Parallel()
+
+	entries, err := parseBlocklist(strings.NewReader(testBlocklist))
+	require.NoError(t, err)
+	blocklist := &Blocklist{entries: entries}
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{
+			name: "Happy Path - not listed",
+			url:  "https://example.org/path",
+			want: false,
+		},
+		{
+			name: "Happy Path - subdomain of a listed domain",
+			url:  "http://www.malware.example",
+			want: false,
+		},
+		{
+			name: "Happy Path - sibling of a listed path",
+			url:  "http://phishing.example/logout",
+			want: false,
+		},
+		{
+			name: "Happy Path - listed path as prefix of another one",
+			url:  "http://phishing.example/login2",
+			want: false,
+		},
+		{
+			name: "Happy Path - comment",
+			url:  "http://malware",
+			want: false,
+		},
+		{
+			name: "Sad Path - hosts file domain",
+			url:  "http://malware.example/download",
+			want: true,
+		},
+		{
+			name: "Sad Path - second hosts file domain",
+			url:  "https://tracker.example",
+			want: true,
+		},
+		{
+			name: "Sad Path - internationalized domain",
+			url:  "https://xn--bcher-kva.example",
+			want: true,
+		},
+		{
+			name: "Sad Path - wildcard domain itself",
+			url:  "http://evil.example",
+			want: true,
+		},
+		{
+			name: "Sad Path - wildcard subdomain",
+			url:  "http://a.b.evil.example/path",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed path",
+			url:  "https://phishing.example/login?next=/",
+			want: true,
+		},
+		{
+			name: "Sad Path - below a listed path",
+			url:  "http://phishing.example/login/step2",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed path with another scheme",
+			url:  "http://example.com/phish",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed path with encoded unreserved characters",
+			url:  "http://phishing.example/%6Cogin",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed path with dot segments",
+			url:  "http://phishing.example/a/../login",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed path with encoded dot segments",
+			url:  "http://phishing.example/a/%2e%2e/login",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed path with empty segments",
+			url:  "http://phishing.example//login",
+			want: true,
+		},
+		{
+			name: "Sad Path - fullwidth host",
+			url:  "http://ｍａｌｗａｒｅ.example/",
+			want: true,
+		},
+		{
+			name: "Sad Path - wildcard domain with ideographic full stop",
+			url:  "http://evil。example/",
+			want: true,
+		},
+		{
+			name: "Sad Path - listed url without path",
+			url:  "http://example.net/anything",
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, blocklist.Blocked(tt.url))
+		})
+	}
+}
+
+func TestBlocklist_Nil(t *testing.T) {
+	t.Parallel()
+
+	var blocklist *Blocklist
+	require.False(t, blocklist.Blocked("http://malware.example"))
+}
+
+func TestBlocklist_Reload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("malware.example\n"), 0o600))
+	blocklist, err := NewBlocklist(path, time.Minute, logger.GetLogger("test", logger.DISABLED))
+	require.NoError(t, err)
+	require.True(t, blocklist.Blocked("http://malware.example"))
+	require.False(t, blocklist.Blocked("http://phishing.example"))
+	stats := blocklist.Stats()
+	require.Equal(t, 1, stats.Entries)
+	require.Empty(t, stats.Error)
+	loadedAt := stats.LoadedAt
+	require.False(t, loadedAt.IsZero())
+
+	reloaded, err := blocklist.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("phishing.example\n"), 0o600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	reloaded, err = blocklist.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.False(t, blocklist.Blocked("http://malware.example"))
+	require.True(t, blocklist.Blocked("http://phishing.example"))
+
+	// a broken file keeps the last blocklist loaded
+	require.NoError(t, os.Remove(path))
+	_, err = blocklist.Reload()
+	require.Error(t, err)
+	require.True(t, blocklist.Blocked("http://phishing.example"))
+	stats = blocklist.Stats()
+	require.Equal(t, 1, stats.Entries)
+	require.Contains(t, stats.Error, "could not stat blocklist")
+	require.False(t, stats.LoadedAt.Before(loadedAt))
+
+	// the error is cleared once the file is fixed
+	require.NoError(t, ioutil.WriteFile(path, []byte("phishing.example\nmalware.example\n"), 0o600))
+	reloaded, err = blocklist.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	stats = blocklist.Stats()
+	require.Equal(t, 2, stats.Entries)
+	require.Empty(t, stats.Error)
+}
+
+func TestNewBlocklist_MissingFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewBlocklist(filepath.Join(t.TempDir(), "missing.txt"), time.Minute, logger.GetLogger("test", logger.DISABLED))
+	require.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package service
+
+import "strings"
+
+// DefaultBotRules are the rules telling apart the bots and crawlers, e.g. link previewers, claiming to be browsers.
+// Most of them name themselves, and the ones that do not are listed by name.
+var DefaultBotRules = []string{
+	// prefixes of browsers, user agents without one, empty ones included, are tools, libraries or bots
+	"^mozilla/", "^opera/",
+	"bot", "crawl", "spider", "slurp", "+http",
+	"facebookexternalhit", "facebookcatalog", "skypeuripreview", "bingpreview", "googleimageproxy", "google favicon",
+	"google-inspectiontool", "feedfetcher", "embedly", "quora link preview", "vkshare", "slack-imgproxy", "nuzzel",
+	"flipboardproxy", "outbrain", "headlesschrome", "phantomjs", "lighthouse", "uptimerobot", "ia_archiver",
+	// exceptions
+	"!cubot", // phones of the brand
+}
+
+// BotClassifier tells bots and crawlers apart from people by the user agent of their requests.
+// A user agent is a bot if it does not start with any of the rules starting with ^, the prefixes of browsers,
+// as empty ones do, or if it contains one of the other rules, ignoring case.
+// Rules starting with ! are exceptions instead: user agents containing one of them are never bots.
+// Rules starting with - remove the same rule given before, e.g. one of DefaultBotRules, so that removing all the prefixes
+// stops classifying user agents, empty ones included, by how they start.
+// It is safe for concurrent use.
+type BotClassifier struct {
+	prefixes   []string
+	rules      []string
+	exceptions []string
+}
+
+// NewBotClassifier returns a new instance of a BotClassifier applying the rules.
+func NewBotClassifier(rules []string) *BotClassifier {
+	var kept []string
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		if strings.HasPrefix(rule, "-") {
+			kept = removeRule(kept, strings.TrimSpace(rule[1:]))
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	c := &BotClassifier{}
+	for _, rule := range kept {
+		switch {
+		case strings.HasPrefix(rule, "^"):
+			if prefix := strings.TrimSpace(rule[1:]); prefix != "" {
+				c.prefixes = append(c.prefixes, prefix)
+			}
+		case strings.HasPrefix(rule, "!"):
+			if exception := strings.TrimSpace(rule[1:]); exception != "" {
+				c.exceptions = append(c.exceptions, exception)
+			}
+		case rule != "":
+			c.rules = append(c.rules, rule)
+		}
+	}
+	return c
+}
+
+// IsBot reports whether the user agent is the one of a bot or a crawler.
+// A nil BotClassifier never finds bots.
+func (c *BotClassifier) IsBot(userAgent string) bool {
+	if c == nil {
+		return false
+	}
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if containsAny(ua, c.exceptions) {
+		return false
+	}
+	if len(c.prefixes) > 0 && !hasAnyPrefix(ua, c.prefixes) {
+		return true
+	}
+	return containsAny(ua, c.rules)
+}
+
+// removeRule returns the rules without the ones equal to rule.
+func removeRule(rules []string, rule string) []string {
+	kept := rules[:0]
+	for _, r := range rules {
+		if r != rule {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyPrefix reports whether s starts with any of the prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
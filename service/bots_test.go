@@ -0,0 +1,145 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readUserAgents reads the user agents of the corpus file in testdata.
+func readUserAgents(t *testing.T, name string) []string {
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+	userAgents, err := ReadWords(f)
+	require.NoError(t, err)
+	require.NotEmpty(t, userAgents)
+	return userAgents
+}
+
+func TestBotClassifier_Corpus(t *testing.T) {
+	t.Parallel()
+
+	bots := NewBotClassifier(DefaultBotRules)
+	for _, ua := range readUserAgents(t, "bots.txt") {
+		require.True(t, bots.IsBot(ua), ua)
+	}
+	for _, ua := range readUserAgents(t, "humans.txt") {
+		require.False(t, bots.IsBot(ua), ua)
+	}
+}
+
+func TestBotClassifier_IsBot(t *testing.T) {
+	t.Parallel()
+
+	bots := NewBotClassifier(append(append([]string{}, DefaultBotRules...), "  ", "!", "MyMonitor", "!Friendly-Tool"))
+	tests := []struct {
+		name      string
+		userAgent string
+		want      bool
+	}{
+		{
+			name:      "Happy Path - browser",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			want:      false,
+		},
+		{
+			name:      "Happy Path - rule ignoring case",
+			userAgent: "Mozilla/5.0 (compatible; MYMONITOR/1.0)",
+			want:      true,
+		},
+		{
+			name:      "Happy Path - not a browser",
+			userAgent: "curl/8.4.0",
+			want:      true,
+		},
+		{
+			name:      "Happy Path - exception beats the rules",
+			userAgent: "Friendly-Tool/1.0 bot",
+			want:      false,
+		},
+		{
+			name:      "Sad Path - empty",
+			userAgent: " ",
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, bots.IsBot(tt.userAgent))
+		})
+	}
+}
+
+func TestBotClassifier_Overrides(t *testing.T) {
+	t.Parallel()
+
+	browser := "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	tests := []struct {
+		name      string
+		rules     []string
+		userAgent string
+		want      bool
+	}{
+		{
+			name:      "Happy Path - prefixes removed",
+			rules:     []string{"-^mozilla/", "-^opera/"},
+			userAgent: "curl/8.4.0",
+			want:      false,
+		},
+		{
+			name:      "Happy Path - empty user agents follow the prefixes",
+			rules:     []string{"-^Mozilla/", "-^opera/"},
+			userAgent: "",
+			want:      false,
+		},
+		{
+			name:      "Happy Path - rules still apply without prefixes",
+			rules:     []string{"-^mozilla/", "-^opera/"},
+			userAgent: "Googlebot/2.1",
+			want:      true,
+		},
+		{
+			name:      "Happy Path - prefix added",
+			rules:     []string{"^curl/"},
+			userAgent: "curl/8.4.0",
+			want:      false,
+		},
+		{
+			name:      "Happy Path - default rule removed",
+			rules:     []string{"- lighthouse"},
+			userAgent: browser + " Lighthouse",
+			want:      false,
+		},
+		{
+			name:      "Happy Path - default exception removed",
+			rules:     []string{"-!cubot"},
+			userAgent: "Mozilla/5.0 (Linux; Android 10; CUBOT X30)",
+			want:      true,
+		},
+		{
+			name:      "Sad Path - removing a rule not given",
+			rules:     []string{"-pizza", "-", "^", "^ "},
+			userAgent: "curl/8.4.0",
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			bots := NewBotClassifier(append(append([]string{}, DefaultBotRules...), tt.rules...))
+			require.Equal(t, tt.want, bots.IsBot(tt.userAgent))
+		})
+	}
+}
+
+func TestBotClassifier_Nil(t *testing.T) {
+	t.Parallel()
+
+	var bots *BotClassifier
+	require.False(t, bots.IsBot(""))
+	require.False(t, bots.IsBot("Googlebot/2.1"))
+}
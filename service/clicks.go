@@ -0,0 +1,270 @@
+//go:generate mockgen -package service -source=clicks.go -destination clicks_mock.go
+
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+)
+
+const (
+	// ipHashSize is the number of bytes of the visitor address hashes, enough to tell visitors apart without being reversible.
+	ipHashSize = 16
+)
+
+// Visit describes the request that resolved a shortened url.
+type Visit struct {
+	IP             string
+	UserAgent      string
+	Referrer       string
+	AcceptLanguage string
+	RequestID      string
+	// Country is the ISO 3166 code of the country of the visitor, if known.
+	Country string
+	// Bot reports whether the visitor is a bot or a crawler, they are not counted among the unique visitors.
+	Bot bool
+}
+
+// ClickRecorder defines the behaviour of a component capable of recording the clicks on shortened urls.
+type ClickRecorder interface {
+	Record(slug string, visit Visit)
+}
+
+// ClickStats are the statistics of a ClickRecorder.
+type ClickStats struct {
+	Pending int    `json:"pending"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// AsyncClickRecorder is a ClickRecorder that buffers clicks in memory and writes them to the repository in bulk,
+// either every interval or as soon as threshold clicks are waiting to be written, so that redirects never wait for it.
+// At most capacity clicks are buffered, the ones beyond are dropped rather than slowing down redirects or running out of memory.
+// Visitor addresses are replaced by their hash, keyed with a salt, before being buffered.
+// Visitors, told apart by their address and user agent, are also counted in HyperLogLog sketches per shortened url and per day,
+// merged into the stored ones along with the clicks, so that unique visitors can be estimated without keeping who they are.
+// It is safe for concurrent use.
+type AsyncClickRecorder struct {
+	store     repository.ClickStorer
+	urls      repository.Storer
+	salt      []byte
+	threshold int
+	capacity  int
+	now       func() time.Time
+	loop      *flushLoop
+
+	mu       sync.Mutex
+	pending  []models.Click
+	visitors map[string]models.Sketch
+	daily    map[dailyVisitors]models.Sketch
+	dropped  uint64
+}
+
+// dailyVisitors identifies the sketch of the visitors of a slug during a day.
+type dailyVisitors struct {
+	slug string
+	day  time.Time
+}
+
+// NewAsyncClickRecorder returns a new instance of an AsyncClickRecorder,
+// writing the clicks to the store and the sketches of the visitors per shortened url to the urls.
+func NewAsyncClickRecorder(store repository.ClickStorer, urls repository.Storer, salt []byte, interval time.Duration, threshold, capacity int) *AsyncClickRecorder {
+	r := &AsyncClickRecorder{
+		store:     store,
+		urls:      urls,
+		salt:      salt,
+		threshold: threshold,
+		capacity:  capacity,
+		now:       time.Now,
+		visitors:  map[string]models.Sketch{},
+		daily:     map[dailyVisitors]models.Sketch{},
+	}
+	r.loop = newFlushLoop(interval, r.Flush)
+	return r
+}
+
+// Record buffers a click on the slug, it never blocks on the repository.
+func (r *AsyncClickRecorder) Record(slug string, visit Visit) {
+	click := models.Click{
+		ID:             uuid.New().String(),
+		Slug:           slug,
+		Time:           r.now().UTC(),
+		Referrer:       visit.Referrer,
+		UserAgent:      visit.UserAgent,
+		IPHash:         r.hashIP(visit.IP),
+		AcceptLanguage: visit.AcceptLanguage,
+		RequestID:      visit.RequestID,
+		Browser:        browserFamily(visit.UserAgent),
+		Country:        strings.ToUpper(visit.Country),
+		Bot:            visit.Bot,
+	}
+	r.mu.Lock()
+	if len(r.pending) >= r.capacity {
+		r.dropped++
+		r.mu.Unlock()
+		return
+	}
+	r.pending = append(r.pending, click)
+	if visit.IP != "" && !visit.Bot {
+		r.addVisitor(click, r.hashVisitor(visit))
+	}
+	full := r.threshold > 0 && len(r.pending) >= r.threshold
+	r.mu.Unlock()
+	if full {
+		r.loop.schedule()
+	}
+}
+
+// hashIP returns the hash of the visitor address keyed with the salt, or an empty string if the address is unknown.
+func (r *AsyncClickRecorder) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(ip)) // nolint: errcheck
+	return hex.EncodeToString(mac.Sum(nil)[:ipHashSize])
+}
+
+// hashVisitor returns the hash of the visitor address and user agent keyed with the salt.
+func (r *AsyncClickRecorder) hashVisitor(visit Visit) uint64 {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(visit.IP))        // nolint: errcheck
+	mac.Write([]byte{0})               // nolint: errcheck
+	mac.Write([]byte(visit.UserAgent)) // nolint: errcheck
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// addVisitor adds the visitor hash to the sketches of the shortened url and of the day of the click.
+// It must be called holding the lock.
+func (r *AsyncClickRecorder) addVisitor(click models.Click, hash uint64) {
+	sketch, ok := r.visitors[click.Slug]
+	if !ok {
+		sketch = models.NewSketch()
+		r.visitors[click.Slug] = sketch
+	}
+	sketch.Add(hash)
+	key := dailyVisitors{slug: click.Slug, day: models.IntervalDay.Truncate(click.Time)}
+	sketch, ok = r.daily[key]
+	if !ok {
+		sketch = models.NewSketch()
+		r.daily[key] = sketch
+	}
+	sketch.Add(hash)
+}
+
+// mergeVisitors merges the sketches back into the buffered ones, so that they can be retried by the next flush.
+// Merging sketches is idempotent, so retrying the ones already written is harmless.
+// It must be called holding the lock.
+func (r *AsyncClickRecorder) mergeVisitors(visitors map[string]models.Sketch, daily map[dailyVisitors]models.Sketch) {
+	for slug, sketch := range visitors {
+		if buffered, ok := r.visitors[slug]; ok {
+			buffered.Merge(sketch)
+			continue
+		}
+		r.visitors[slug] = sketch
+	}
+	for key, sketch := range daily {
+		if buffered, ok := r.daily[key]; ok {
+			buffered.Merge(sketch)
+			continue
+		}
+		r.daily[key] = sketch
+	}
+}
+
+// Start writes the buffered clicks and merges the sketches of their visitors every interval,
+// or as soon as threshold clicks are waiting, until the context is cancelled or Shutdown is called. It blocks until then.
+func (r *AsyncClickRecorder) Start(ctx context.Context) {
+	r.loop.run(ctx)
+}
+
+// Flush writes all the buffered clicks to the repository, then merges the sketches of their visitors into the stored ones.
+// Clicks that could not be written are buffered again, as long as there is room for them, so that they can be retried by the next flush,
+// and so are the sketches that could not be merged. Clicks are retried as a whole, the ones already written are skipped by their ID.
+// Returns an error if any.
+func (r *AsyncClickRecorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	clicks, visitors, daily := r.pending, r.visitors, r.daily
+	r.pending = nil
+	r.visitors, r.daily = map[string]models.Sketch{}, map[dailyVisitors]models.Sketch{}
+	r.mu.Unlock()
+	if len(clicks) == 0 && len(visitors) == 0 {
+		return nil
+	}
+	err := r.addClicks(ctx, clicks)
+	if err != nil {
+		r.mu.Lock()
+		r.mergeVisitors(visitors, daily)
+		room := r.capacity - len(r.pending)
+		if room < 0 {
+			room = 0
+		}
+		if len(clicks) > room {
+			r.dropped += uint64(len(clicks) - room)
+			clicks = clicks[:room]
+		}
+		r.pending = append(clicks, r.pending...)
+		r.mu.Unlock()
+		return fmt.Errorf("could not flush clicks: %w", err)
+	}
+	if len(visitors) == 0 {
+		return nil
+	}
+	err = r.flushVisitors(ctx, visitors, daily)
+	if err != nil {
+		r.mu.Lock()
+		r.mergeVisitors(visitors, daily)
+		r.mu.Unlock()
+		return fmt.Errorf("could not flush visitors: %w", err)
+	}
+	return nil
+}
+
+// addClicks writes the clicks to the repository, if any.
+// Returns an error if any.
+func (r *AsyncClickRecorder) addClicks(ctx context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	return r.store.AddClicks(ctx, clicks)
+}
+
+// flushVisitors merges the sketches of the visitors per shortened url and per day into the stored ones.
+// Returns an error if any.
+func (r *AsyncClickRecorder) flushVisitors(ctx context.Context, visitors map[string]models.Sketch, daily map[dailyVisitors]models.Sketch) error {
+	err := r.urls.MergeVisitors(ctx, visitors)
+	if err != nil {
+		return err
+	}
+	sketches := make([]models.DailySketch, 0, len(daily))
+	for key, sketch := range daily {
+		sketches = append(sketches, models.DailySketch{Slug: key.slug, Day: key.day, Sketch: sketch})
+	}
+	return r.store.MergeDailyVisitors(ctx, sketches)
+}
+
+// Stats returns the statistics of the recorder.
+func (r *AsyncClickRecorder) Stats() ClickStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ClickStats{
+		Pending: len(r.pending),
+		Dropped: r.dropped,
+	}
+}
+
+// Shutdown stops the periodic flushing and writes the clicks and the visitor sketches still buffered.
+// Returns an error if any.
+func (r *AsyncClickRecorder) Shutdown(context.Context) error {
+	return r.loop.drain()
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncClickRecorder_Record(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := repository.NewMockClickStorer(ctrl)
+	clicks := NewAsyncClickRecorder(mockStore, nil, []byte("salt"), time.Hour, 0, 10)
+	clicks.now = func() time.Time { return now }
+
+	clicks.Record("pizza", Visit{
+		IP:             "203.0.113.7",
+		UserAgent:      "Mozilla/5.0",
+		Referrer:       "https://news.example",
+		AcceptLanguage: "en-GB,en;q=0.9",
+		RequestID:      "r1",
+		Country:        "gb",
+	})
+	clicks.Record("pizza", Visit{IP: "203.0.113.7"})
+	clicks.Record("pizza", Visit{IP: "203.0.113.8"})
+	clicks.Record("pizza", Visit{})
+
+	require.Len(t, clicks.pending, 4)
+	first := clicks.pending[0]
+	require.Equal(t, models.Click{
+		ID:             first.ID,
+		Slug:           "pizza",
+		Time:           now,
+		Referrer:       "https://news.example",
+		UserAgent:      "Mozilla/5.0",
+		IPHash:         first.IPHash,
+		AcceptLanguage: "en-GB,en;q=0.9",
+		RequestID:      "r1",
+		Browser:        "Other",
+		Country:        "GB",
+	}, first)
+	require.NotEmpty(t, first.ID)
+	require.NotEqual(t, first.ID, clicks.pending[1].ID, "every click has its own id")
+	require.Len(t, first.IPHash, 2*ipHashSize)
+	require.NotContains(t, first.IPHash, "203.0.113.7")
+	require.Equal(t, first.IPHash, clicks.pending[1].IPHash, "the same address has the same hash")
+	require.NotEqual(t, first.IPHash, clicks.pending[2].IPHash, "different addresses have different hashes")
+	require.Empty(t, clicks.pending[3].IPHash)
+
+	salted := NewAsyncClickRecorder(mockStore, nil, []byte("pepper"), time.Hour, 0, 10)
+	require.NotEqual(t, first.IPHash, salted.hashIP("203.0.113.7"), "hashes depend on the salt")
+}
+
+func TestAsyncClickRecorder_Flush(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		clicks            []string
+		capacity          int
+		setupExpectations func(store *repository.MockClickStorer)
+		wanterr           bool
+		wantPending       int
+		wantDropped       uint64
+	}{
+		{
+			name:     "Happy Path",
+			clicks:   []string{"pizza", "pizza", "short"},
+			capacity: 10,
+			setupExpectations: func(store *repository.MockClickStorer) {
+				store.EXPECT().AddClicks(gomock.Any(), gomock.Len(3)).Return(nil)
+			},
+			wanterr:     false,
+			wantPending: 0,
+		},
+		{
+			name:              "Happy Path - nothing to flush",
+			clicks:            nil,
+			capacity:          10,
+			setupExpectations: func(store *repository.MockClickStorer) {},
+			wanterr:           false,
+			wantPending:       0,
+		},
+		{
+			name:     "Happy Path - clicks beyond capacity are dropped",
+			clicks:   []string{"pizza", "pizza", "short"},
+			capacity: 2,
+			setupExpectations: func(store *repository.MockClickStorer) {
+				store.EXPECT().AddClicks(gomock.Any(), gomock.Len(2)).Return(nil)
+			},
+			wanterr:     false,
+			wantPending: 0,
+			wantDropped: 1,
+		},
+		{
+			name:     "Sad Path - clicks are buffered again on failure",
+			clicks:   []string{"pizza", "pizza", "short"},
+			capacity: 10,
+			setupExpectations: func(store *repository.MockClickStorer) {
+				store.EXPECT().AddClicks(gomock.Any(), gomock.Any()).Return(errors.New("unexpected error"))
+			},
+			wanterr:     true,
+			wantPending: 3,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStore := repository.NewMockClickStorer(ctrl)
+			tt.setupExpectations(mockStore)
+
+			clicks := NewAsyncClickRecorder(mockStore, nil, nil, time.Hour, 0, tt.capacity)
+			for _, slug := range tt.clicks {
+				clicks.Record(slug, Visit{})
+			}
+			err := clicks.Flush(context.Background())
+			require.Equal(t, tt.wanterr, err != nil)
+			require.Equal(t, ClickStats{Pending: tt.wantPending, Dropped: tt.wantDropped}, clicks.Stats())
+		})
+	}
+}
+
+func TestAsyncClickRecorder_FlushPartialFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := repository.NewMemoryClickStorer()
+	mockStore := repository.NewMockClickStorer(ctrl)
+	gomock.InOrder(
+		// the unordered bulk insert writes some of the clicks only
+		mockStore.EXPECT().AddClicks(gomock.Any(), gomock.Len(3)).DoAndReturn(
+			func(ctx context.Context, clicks []models.Click) error {
+				require.NoError(t, store.AddClicks(ctx, clicks[:2]))
+				return errors.New("unexpected error")
+			}),
+		mockStore.EXPECT().AddClicks(gomock.Any(), gomock.Len(3)).DoAndReturn(store.AddClicks),
+	)
+	clicks := NewAsyncClickRecorder(mockStore, nil, nil, time.Hour, 0, 10)
+	clicks.Record("pizza", Visit{RequestID: "r1"})
+	clicks.Record("pizza", Visit{RequestID: "r2"})
+	clicks.Record("pizza", Visit{RequestID: "r3"})
+	require.Error(t, clicks.Flush(ctx))
+	require.NoError(t, clicks.Flush(ctx))
+
+	var ids []string
+	err := store.IterateClicks(ctx, repository.ClickQuery{Slug: "pizza"}, func(click models.Click) error {
+		ids = append(ids, click.RequestID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"r1", "r2", "r3"}, ids, "retried clicks are written once")
+}
+
+func TestAsyncClickRecorder_FlushOnThreshold(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := repository.NewMockClickStorer(ctrl)
+	flushed := make(chan []models.Click, 1)
+	mockStore.EXPECT().AddClicks(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, clicks []models.Click) error {
+			flushed <- clicks
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clicks := NewAsyncClickRecorder(mockStore, nil, nil, time.Hour, 2, 10)
+	go clicks.Start(ctx)
+	clicks.Record("pizza", Visit{})
+	clicks.Record("short", Visit{})
+
+	select {
+	case got := <-flushed:
+		require.Len(t, got, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("clicks were not flushed after reaching the threshold")
+	}
+}
+
+func TestAsyncClickRecorder_Shutdown(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := repository.NewMockClickStorer(ctrl)
+	mockStore.EXPECT().AddClicks(gomock.Any(), gomock.Len(3)).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clicks := NewAsyncClickRecorder(mockStore, nil, nil, time.Hour, 0, 10)
+	go clicks.Start(ctx)
+	clicks.Record("pizza", Visit{})
+	clicks.Record("pizza", Visit{})
+	clicks.Record("pizza", Visit{})
+
+	// shutdown.Wait cancels the context before invoking the termination functions
+	cancel()
+	err := clicks.Shutdown(ctx)
+	require.NoError(t, err)
+}
+
+func TestAsyncClickRecorder_FlushVisitors(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	urls := repository.NewMemoryURLStorer()
+	require.NoError(t, urls.Add(ctx, models.URLShortened{Slug: "pizza", URL: "http://indiependente.dev"}))
+	store := repository.NewMemoryClickStorer()
+	clicks := NewAsyncClickRecorder(store, urls, []byte("salt"), time.Hour, 0, 10)
+	clicks.now = func() time.Time { return now }
+
+	clicks.Record("pizza", Visit{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"})
+	clicks.Record("pizza", Visit{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"})
+	clicks.Record("pizza", Visit{IP: "203.0.113.7", UserAgent: "curl/8.4.0"})
+	clicks.Record("pizza", Visit{IP: "203.0.113.8", UserAgent: "Mozilla/5.0"})
+	// unknown visitors and bots are not counted
+	clicks.Record("pizza", Visit{})
+	clicks.Record("pizza", Visit{IP: "203.0.113.9", UserAgent: "Googlebot/2.1", Bot: true})
+	clicks.now = func() time.Time { return now.Add(24 * time.Hour) }
+	clicks.Record("pizza", Visit{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"})
+	require.NoError(t, clicks.Flush(ctx))
+
+	visitors, err := urls.Visitors(ctx, "pizza")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), visitors.Estimate())
+	daily, err := store.DailyVisitors(ctx, repository.ClickQuery{Slug: "pizza"})
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	require.Equal(t, now.Truncate(24*time.Hour), daily[0].Day)
+	require.Equal(t, uint64(3), daily[0].Sketch.Estimate())
+	require.Equal(t, uint64(1), daily[1].Sketch.Estimate())
+	var bots int
+	err = store.IterateClicks(ctx, repository.ClickQuery{Slug: "pizza"}, func(click models.Click) error {
+		if click.Bot {
+			bots++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, bots, "bot clicks are recorded as such")
+
+	// sketches that could not be merged are retried by the next flush, even without new clicks
+	mockURLs := repository.NewMockStorer(ctrl)
+	failing := NewAsyncClickRecorder(store, mockURLs, []byte("salt"), time.Hour, 0, 10)
+	gomock.InOrder(
+		mockURLs.EXPECT().MergeVisitors(gomock.Any(), gomock.Len(1)).Return(errors.New("unexpected error")),
+		mockURLs.EXPECT().MergeVisitors(gomock.Any(), gomock.Len(1)).Return(nil),
+	)
+	failing.Record("pasta", Visit{IP: "203.0.113.7"})
+	require.Error(t, failing.Flush(ctx))
+	require.NoError(t, failing.Flush(ctx))
+	require.NoError(t, failing.Flush(ctx), "nothing left to flush")
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+)
+
+// ExportQuery selects the time range [From, To) of the clicks to export.
+// Zero values leave the range open on their side.
+type ExportQuery struct {
+	From, To time.Time
+}
+
+// ClickIterator calls fn with the exported clicks, one at a time and in chronological order,
+// until fn returns an error, which is then returned as is.
+// Clicks are read as they are iterated, so that they never need to fit in memory all together.
+type ClickIterator func(ctx context.Context, fn func(models.Click) error) error
+
+// ExportClicks returns an iterator over the clicks on the shortened url of the slug in the time range of the query.
+// Clicks of expired shortened urls can still be exported.
+// Returns an error if any.
+func (usvc URLService) ExportClicks(ctx context.Context, slug string, query ExportQuery) (ClickIterator, error) {
+	if usvc.clicks == nil {
+		return nil, fmt.Errorf("could not export clicks: clicks are not stored")
+	}
+	if err := validExportQuery(query); err != nil {
+		return nil, err
+	}
+	url, err := usvc.lookup(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return usvc.iterateClicks(repository.ClickQuery{Slug: url.Slug, From: query.From, To: query.To}), nil
+}
+
+// ExportAllClicks returns an iterator over the clicks on every shortened url in the time range of the query,
+// expired ones included. Both ends of the range are required, so that exports are split up rather than ever growing.
+// Returns an error if any.
+func (usvc URLService) ExportAllClicks(ctx context.Context, query ExportQuery) (ClickIterator, error) {
+	if usvc.clicks == nil {
+		return nil, fmt.Errorf("could not export clicks: clicks are not stored")
+	}
+	if query.From.IsZero() || query.To.IsZero() {
+		return nil, fmt.Errorf("from and to required: %w", ErrInvalidExportQuery)
+	}
+	if err := validExportQuery(query); err != nil {
+		return nil, err
+	}
+	return usvc.iterateClicks(repository.ClickQuery{From: query.From, To: query.To}), nil
+}
+
+// iterateClicks returns an iterator over the clicks selected by the query.
+func (usvc URLService) iterateClicks(query repository.ClickQuery) ClickIterator {
+	return func(ctx context.Context, fn func(models.Click) error) error {
+		return usvc.clicks.IterateClicks(ctx, query, fn)
+	}
+}
+
+// validExportQuery checks the time range of the query is not empty, unless open.
+// Returns an error if any.
+func validExportQuery(query ExportQuery) error {
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return fmt.Errorf("from not before to: %w", ErrInvalidExportQuery)
+	}
+	return nil
+}
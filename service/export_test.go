@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLService_ExportClicks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	require.NoError(t, store.Add(ctx, models.URLShortened{Slug: "pizza", URL: "http://indiependente.dev"}))
+	require.NoError(t, store.Add(ctx, models.URLShortened{Slug: "gone", URL: "http://indiependente.dev", ExpiresAt: &anHourAgo, FoldedSlug: true}))
+	clicks := repository.NewMemoryClickStorer()
+	require.NoError(t, clicks.AddClicks(ctx, []models.Click{
+		{Slug: "pizza", Time: now.Add(-time.Hour), RequestID: "r3"},
+		{Slug: "pizza", Time: now.Add(-3 * time.Hour), RequestID: "r1"},
+		{Slug: "gone", Time: now.Add(-2 * time.Hour), RequestID: "r2"},
+		{Slug: "pizza", Time: now, RequestID: "r4"},
+	}))
+
+	tests := []struct {
+		name    string
+		slug    string
+		query   ExportQuery
+		want    []string
+		wanterr error
+	}{
+		{
+			name:  "Happy Path - all time",
+			slug:  "pizza",
+			query: ExportQuery{},
+			want:  []string{"r1", "r3", "r4"},
+		},
+		{
+			name:  "Happy Path - range",
+			slug:  "pizza",
+			query: ExportQuery{From: now.Add(-2 * time.Hour), To: now},
+			want:  []string{"r3"},
+		},
+		{
+			name:  "Happy Path - expired",
+			slug:  "gone",
+			query: ExportQuery{},
+			want:  []string{"r2"},
+		},
+		{
+			name:  "Happy Path - expired, typed in another case",
+			slug:  "Gone",
+			query: ExportQuery{},
+			want:  []string{"r2"},
+		},
+		{
+			name:    "Sad Path - slug not found",
+			slug:    "pasta",
+			wanterr: ErrSlugNotFound,
+		},
+		{
+			name:    "Sad Path - from not before to",
+			slug:    "pizza",
+			query:   ExportQuery{From: now, To: now},
+			wanterr: ErrInvalidExportQuery,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			usvc := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(ctrl), WithClickStore(clicks))
+
+			iterate, err := usvc.ExportClicks(ctx, tt.slug, tt.query)
+			if tt.wanterr != nil {
+				require.ErrorIs(t, err, tt.wanterr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, exported(ctx, t, iterate))
+		})
+	}
+}
+
+func TestURLService_ExportAllClicks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	clicks := repository.NewMemoryClickStorer()
+	require.NoError(t, clicks.AddClicks(ctx, []models.Click{
+		{Slug: "pizza", Time: now.Add(-time.Hour), RequestID: "r3"},
+		{Slug: "deleted", Time: now.Add(-2 * time.Hour), RequestID: "r2"},
+		{Slug: "pizza", Time: now.Add(-3 * time.Hour), RequestID: "r1"},
+	}))
+	usvc := NewURLService(repository.NewMemoryURLStorer(), NewFixedLenSlugger(5), NewMockHitCounter(ctrl), WithClickStore(clicks))
+
+	iterate, err := usvc.ExportAllClicks(ctx, ExportQuery{From: now.Add(-2 * time.Hour), To: now})
+	require.NoError(t, err)
+	require.Equal(t, []string{"r2", "r3"}, exported(ctx, t, iterate))
+
+	stop := errors.New("stop")
+	err = iterate(ctx, func(models.Click) error { return stop })
+	require.Equal(t, stop, err, "the error of fn is returned as is")
+
+	_, err = usvc.ExportAllClicks(ctx, ExportQuery{From: now.Add(-time.Hour)})
+	require.ErrorIs(t, err, ErrInvalidExportQuery, "the range is required")
+	_, err = usvc.ExportAllClicks(ctx, ExportQuery{From: now, To: now.Add(-time.Hour)})
+	require.ErrorIs(t, err, ErrInvalidExportQuery)
+
+	unstored := NewURLService(repository.NewMemoryURLStorer(), NewFixedLenSlugger(5), NewMockHitCounter(ctrl))
+	_, err = unstored.ExportAllClicks(ctx, ExportQuery{From: now.Add(-time.Hour), To: now})
+	require.Error(t, err)
+}
+
+// exported returns the request ids of the clicks of the iterator, in the order they are iterated.
+func exported(ctx context.Context, t *testing.T, iterate ClickIterator) []string {
+	var ids []string
+	err := iterate(ctx, func(click models.Click) error {
+		ids = append(ids, click.RequestID)
+		return nil
+	})
+	require.NoError(t, err)
+	return ids
+}
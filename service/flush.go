@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// flushTimeout is the time a single flush has to write the buffered writes to the repository.
+	flushTimeout = 10 * time.Second
+)
+
+// flushLoop runs the flush of the writes buffered in memory every interval, or as soon as one is scheduled, until it is stopped.
+// It is safe for concurrent use.
+type flushLoop struct {
+	interval time.Duration
+	flush    func(context.Context) error
+
+	scheduled chan struct{}
+	stop      chan struct{}
+	once      sync.Once
+}
+
+// newFlushLoop returns a new flushLoop running the flush every interval.
+func newFlushLoop(interval time.Duration, flush func(context.Context) error) *flushLoop {
+	return &flushLoop{
+		interval:  interval,
+		flush:     flush,
+		scheduled: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+}
+
+// schedule runs a flush without waiting for the interval, it never blocks.
+func (l *flushLoop) schedule() {
+	select {
+	case l.scheduled <- struct{}{}:
+	default: // a flush is already scheduled
+	}
+}
+
+// run flushes every interval and whenever a flush is scheduled, until the context is cancelled or the loop is stopped.
+// Failed flushes are left to the flush to retry, by buffering again what it could not write.
+func (l *flushLoop) run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.scheduled:
+		case <-ctx.Done():
+			return
+		case <-l.stop:
+			return
+		}
+		_ = l.flushWithTimeout()
+	}
+}
+
+// drain stops the loop, once, and runs a last flush bound by its own timeout,
+// since the context of a shutdown is usually already cancelled.
+// Returns an error if any.
+func (l *flushLoop) drain() error {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+	return l.flushWithTimeout()
+}
+
+func (l *flushLoop) flushWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return l.flush(ctx)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushLoop_Schedule(t *testing.T) {
+	t.Parallel()
+	flushed := make(chan bool, 1)
+	loop := newFlushLoop(time.Hour, func(ctx context.Context) error {
+		_, timeout := ctx.Deadline()
+		flushed <- timeout
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go loop.run(ctx)
+
+	loop.schedule()
+	loop.schedule() // never blocks, even with a flush already scheduled
+	select {
+	case timeout := <-flushed:
+		require.True(t, timeout, "flush without timeout")
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled flush did not run")
+	}
+}
+
+func TestFlushLoop_Drain(t *testing.T) {
+	t.Parallel()
+	errFlush := errors.New("boom")
+	var flushes int32
+	loop := newFlushLoop(time.Hour, func(context.Context) error {
+		atomic.AddInt32(&flushes, 1)
+		return errFlush
+	})
+	stopped := make(chan struct{})
+	go func() {
+		loop.run(context.Background())
+		close(stopped)
+	}()
+
+	require.ErrorIs(t, loop.drain(), errFlush)
+	// draining again flushes again, without stopping twice
+	require.ErrorIs(t, loop.drain(), errFlush)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("loop did not stop")
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&flushes))
+}
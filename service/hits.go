@@ -0,0 +1,136 @@
+//go:generate mockgen -package service -source=hits.go -destination hits_mock.go
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/indiependente/shrtnr/repository"
+)
+
+// HitCounter defines the behaviour of a component capable of counting hits on shortened urls,
+// the ones of bots and crawlers apart from the ones of people.
+type HitCounter interface {
+	Hit(slug string)
+	BotHit(slug string)
+}
+
+// HitAggregator is a HitCounter that buffers hits in memory and writes them to the repository in bulk,
+// either every interval or as soon as threshold distinct slugs are waiting to be written, bot hits included.
+// It is safe for concurrent use.
+type HitAggregator struct {
+	store     repository.Storer
+	threshold int
+	loop      *flushLoop
+
+	mu      sync.Mutex
+	pending map[string]int
+	bots    map[string]int
+}
+
+// NewHitAggregator returns a new instance of a HitAggregator.
+func NewHitAggregator(store repository.Storer, interval time.Duration, threshold int) *HitAggregator {
+	a := &HitAggregator{
+		store:     store,
+		threshold: threshold,
+		pending:   map[string]int{},
+		bots:      map[string]int{},
+	}
+	a.loop = newFlushLoop(interval, a.Flush)
+	return a
+}
+
+// Hit buffers a hit on the slug, it never blocks on the repository.
+func (a *HitAggregator) Hit(slug string) {
+	a.hit(slug, false)
+}
+
+// BotHit buffers a hit of a bot on the slug, it never blocks on the repository.
+func (a *HitAggregator) BotHit(slug string) {
+	a.hit(slug, true)
+}
+
+// hit buffers a hit on the slug, of a bot or not, scheduling a flush once there are too many.
+// The buffer is picked under the lock, since Flush swaps the buffers.
+func (a *HitAggregator) hit(slug string, bot bool) {
+	a.mu.Lock()
+	pending := a.pending
+	if bot {
+		pending = a.bots
+	}
+	pending[slug]++
+	full := a.threshold > 0 && len(a.pending)+len(a.bots) >= a.threshold
+	a.mu.Unlock()
+	if full {
+		a.loop.schedule()
+	}
+}
+
+// Start writes the buffered hits every interval, or as soon as threshold slugs are waiting,
+// until the context is cancelled or Shutdown is called. It blocks until then.
+func (a *HitAggregator) Start(ctx context.Context) {
+	a.loop.run(ctx)
+}
+
+// Flush writes all the buffered hits to the repository.
+// Hits that could not be written are buffered again, so that they can be retried by the next flush.
+// When only some of the writes failed, only their hits are buffered again, so that the written ones are never counted twice.
+// Returns an error if any.
+func (a *HitAggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	deltas, bots := a.pending, a.bots
+	a.pending, a.bots = map[string]int{}, map[string]int{}
+	a.mu.Unlock()
+	if len(deltas) > 0 {
+		err := a.store.BulkIncrementHits(ctx, deltas)
+		if err != nil {
+			a.rebuffer(unwritten(deltas, err), bots)
+			return fmt.Errorf("could not flush hits: %w", err)
+		}
+	}
+	if len(bots) > 0 {
+		err := a.store.BulkIncrementBotHits(ctx, bots)
+		if err != nil {
+			a.rebuffer(nil, unwritten(bots, err))
+			return fmt.Errorf("could not flush bot hits: %w", err)
+		}
+	}
+	return nil
+}
+
+// unwritten returns the deltas that the failed write did not write: the failed ones if it reports them, all of them otherwise.
+func unwritten(deltas map[string]int, err error) map[string]int {
+	var partial *repository.PartialWriteError
+	if !errors.As(err, &partial) {
+		return deltas
+	}
+	failed := make(map[string]int, len(partial.Failed))
+	for _, slug := range partial.Failed {
+		if delta, ok := deltas[slug]; ok {
+			failed[slug] = delta
+		}
+	}
+	return failed
+}
+
+// rebuffer buffers again the hits and the bot hits that could not be written, so that they can be retried by the next flush.
+func (a *HitAggregator) rebuffer(deltas, bots map[string]int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for slug, delta := range deltas {
+		a.pending[slug] += delta
+	}
+	for slug, delta := range bots {
+		a.bots[slug] += delta
+	}
+}
+
+// Shutdown stops the periodic flushing and writes the hits still buffered, so that no hit is lost on shutdown.
+// Returns an error if any.
+func (a *HitAggregator) Shutdown(context.Context) error {
+	return a.loop.drain()
+}
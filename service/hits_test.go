@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHitAggregator_Flush(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		hits              []string
+		botHits           []string
+		setupExpectations func(store *repository.MockStorer)
+		wanterr           bool
+		wantPending       map[string]int
+		wantBots          map[string]int
+	}{
+		{
+			name: "Happy Path",
+			hits: []string{"pizza", "pizza", "short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementHits(gomock.Any(), map[string]int{"pizza": 2, "short": 1}).Return(nil)
+			},
+			wanterr:     false,
+			wantPending: map[string]int{},
+			wantBots:    map[string]int{},
+		},
+		{
+			name:              "Happy Path - nothing to flush",
+			hits:              nil,
+			setupExpectations: func(store *repository.MockStorer) {},
+			wanterr:           false,
+			wantPending:       map[string]int{},
+			wantBots:          map[string]int{},
+		},
+		{
+			name: "Sad Path - hits are buffered again on failure",
+			hits: []string{"pizza", "pizza", "short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementHits(gomock.Any(), gomock.Any()).Return(errors.New("unexpected error"))
+			},
+			wanterr:     true,
+			wantPending: map[string]int{"pizza": 2, "short": 1},
+			wantBots:    map[string]int{},
+		},
+		{
+			name: "Sad Path - only the failed hits are buffered again",
+			hits: []string{"pizza", "pizza", "short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementHits(gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("could not increment hits: %w", &repository.PartialWriteError{
+						Failed: []string{"short"},
+						Err:    errors.New("unexpected error"),
+					}))
+			},
+			wanterr:     true,
+			wantPending: map[string]int{"short": 1},
+			wantBots:    map[string]int{},
+		},
+		{
+			name:    "Sad Path - only the failed bot hits are buffered again",
+			botHits: []string{"pizza", "pizza", "short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementBotHits(gomock.Any(), gomock.Any()).
+					Return(&repository.PartialWriteError{Failed: []string{"pizza"}, Err: errors.New("unexpected error")})
+			},
+			wanterr:     true,
+			wantPending: map[string]int{},
+			wantBots:    map[string]int{"pizza": 2},
+		},
+		{
+			name:    "Happy Path - bot hits",
+			hits:    []string{"pizza"},
+			botHits: []string{"pizza", "pizza", "short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementHits(gomock.Any(), map[string]int{"pizza": 1}).Return(nil)
+				store.EXPECT().BulkIncrementBotHits(gomock.Any(), map[string]int{"pizza": 2, "short": 1}).Return(nil)
+			},
+			wanterr:     false,
+			wantPending: map[string]int{},
+			wantBots:    map[string]int{},
+		},
+		{
+			name:    "Sad Path - bot hits are buffered again on failure",
+			hits:    []string{"pizza"},
+			botHits: []string{"pizza", "pizza", "short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementHits(gomock.Any(), map[string]int{"pizza": 1}).Return(nil)
+				store.EXPECT().BulkIncrementBotHits(gomock.Any(), gomock.Any()).Return(errors.New("unexpected error"))
+			},
+			wanterr:     true,
+			wantPending: map[string]int{},
+			wantBots:    map[string]int{"pizza": 2, "short": 1},
+		},
+		{
+			name:    "Sad Path - bot hits are buffered again when hits fail",
+			hits:    []string{"pizza"},
+			botHits: []string{"short"},
+			setupExpectations: func(store *repository.MockStorer) {
+				store.EXPECT().BulkIncrementHits(gomock.Any(), gomock.Any()).Return(errors.New("unexpected error"))
+			},
+			wanterr:     true,
+			wantPending: map[string]int{"pizza": 1},
+			wantBots:    map[string]int{"short": 1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStore := repository.NewMockStorer(ctrl)
+			tt.setupExpectations(mockStore)
+
+			hits := NewHitAggregator(mockStore, time.Hour, 0)
+			for _, slug := range tt.hits {
+				hits.Hit(slug)
+			}
+			for _, slug := range tt.botHits {
+				hits.BotHit(slug)
+			}
+			err := hits.Flush(context.Background())
+			require.Equal(t, tt.wanterr, err != nil)
+			require.Equal(t, tt.wantPending, hits.pending)
+			require.Equal(t, tt.wantBots, hits.bots)
+		})
+	}
+}
+
+func TestHitAggregator_FlushOnThreshold(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := repository.NewMockStorer(ctrl)
+	flushed := make(chan map[string]int, 1)
+	mockStore.EXPECT().BulkIncrementHits(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, deltas map[string]int) error {
+			flushed <- deltas
+			return nil
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hits := NewHitAggregator(mockStore, time.Hour, 2)
+	go hits.Start(ctx)
+	hits.Hit("pizza")
+	hits.Hit("pizza")
+	hits.Hit("short")
+
+	select {
+	case deltas := <-flushed:
+		require.Equal(t, map[string]int{"pizza": 2, "short": 1}, deltas)
+	case <-time.After(5 * time.Second):
+		t.Fatal("hits were not flushed after reaching the threshold")
+	}
+}
+
+func TestHitAggregator_Shutdown(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := repository.NewMockStorer(ctrl)
+	mockStore.EXPECT().BulkIncrementHits(gomock.Any(), map[string]int{"pizza": 3}).Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hits := NewHitAggregator(mockStore, time.Hour, 0)
+	go hits.Start(ctx)
+	hits.Hit("pizza")
+	hits.Hit("pizza")
+	hits.Hit("pizza")
+
+	// shutdown.Wait cancels the context before invoking the termination functions
+	cancel()
+	err := hits.Shutdown(ctx)
+	require.NoError(t, err)
+}
+
+func TestHitAggregator_ConcurrentHitsAndFlushes(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockStore := repository.NewMockStorer(ctrl)
+	var mu sync.Mutex
+	written := map[string]int{}
+	botsWritten := map[string]int{}
+	write := func(into map[string]int) func(context.Context, map[string]int) error {
+		return func(_ context.Context, deltas map[string]int) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for slug, delta := range deltas {
+				into[slug] += delta
+			}
+			return nil
+		}
+	}
+	mockStore.EXPECT().BulkIncrementHits(gomock.Any(), gomock.Any()).DoAndReturn(write(written)).AnyTimes()
+	mockStore.EXPECT().BulkIncrementBotHits(gomock.Any(), gomock.Any()).DoAndReturn(write(botsWritten)).AnyTimes()
+
+	ctx := context.Background()
+	hits := NewHitAggregator(mockStore, time.Hour, 0)
+	const workers, perWorker = 8, 500
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				hits.Hit("pizza")
+				hits.BotHit("pizza")
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for flushing := true; flushing; {
+		select {
+		case <-done:
+			flushing = false
+		default:
+			require.NoError(t, hits.Flush(ctx))
+		}
+	}
+	require.NoError(t, hits.Flush(ctx))
+
+	// no hit is lost to a buffer swapped by a flush
+	require.Equal(t, map[string]int{"pizza": workers * perWorker}, written)
+	require.Equal(t, map[string]int{"pizza": workers * perWorker}, botsWritten)
+}
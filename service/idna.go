@@ -0,0 +1,35 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// toASCII returns the domain name as browsers look it up: internationalized names are mapped as per UTS 46,
+// e.g. fullwidth letters to ASCII ones and ideographic full stops to dots, and their labels encoded with punycode.
+// ASCII names are only lowercased, so that the names valid for DNS but not for IDNA, e.g. with underscores, are kept.
+// The trailing dot, if any, is removed.
+// Returns an error if any.
+func toASCII(host string) (string, error) {
+	if !isASCII(host) {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("could not map %q: %w", host, err)
+		}
+		host = ascii
+	}
+	return strings.TrimSuffix(strings.ToLower(host), "."), nil
+}
+
+// isASCII reports whether s is made of ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
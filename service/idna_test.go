@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToASCII(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{
+			name: "Happy Path - ascii",
+			host: "Example.com",
+			want: "example.com",
+		},
+		{
+			name: "Happy Path - mixed label",
+			host: "münchen.de",
+			want: "xn--mnchen-3ya.de",
+		},
+		{
+			name: "Happy Path - uppercase label",
+			host: "BÜCHER.example",
+			want: "xn--bcher-kva.example",
+		},
+		{
+			name: "Happy Path - non ascii labels only",
+			host: "例え.テスト",
+			want: "xn--r8jz45g.xn--zckzah",
+		},
+		{
+			name: "Happy Path - single symbol",
+			host: "☃.net",
+			want: "xn--n3h.net",
+		},
+		{
+			name: "Happy Path - fullwidth letters",
+			host: "ｌｏｃａｌｈｏｓｔ",
+			want: "localhost",
+		},
+		{
+			name: "Happy Path - ideographic full stops",
+			host: "127。0。0。1",
+			want: "127.0.0.1",
+		},
+		{
+			name: "Happy Path - fullwidth uppercase letters",
+			host: "ＳＨＯ.ＲＴ",
+			want: "sho.rt",
+		},
+		{
+			name: "Happy Path - trailing ideographic full stop",
+			host: "evil。example。",
+			want: "evil.example",
+		},
+		{
+			name: "Happy Path - ascii not valid for idna",
+			host: "my_host.Example.com",
+			want: "my_host.example.com",
+		},
+		{
+			name: "Happy Path - RFC 3492 sample",
+			host: "他们为什么不说中文",
+			want: "xn--ihqwcrb4cv8a8dqg056pqjye",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toASCII(tt.host)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
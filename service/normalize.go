@@ -0,0 +1,260 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultTrackingParams are the query parameters added by marketing and analytics tools,
+// which do not change the resource a url points to.
+// Names ending with * stand for every parameter starting with the rest of the name.
+var DefaultTrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid", "_hsenc", "_hsmi",
+}
+
+// defaultPorts are the ports implied by the schemes, which need not be spelled out.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// URLNormalizer turns urls into a canonical form, so that the urls pointing to the same resource
+// are shortened by the same slug:
+//   - the url gets the http scheme if it has none, a host followed by a port is not mistaken for a scheme
+//   - scheme and host are lowercased, internationalized hosts are mapped as browsers do, as per UTS 46, and encoded with punycode
+//   - the default port of the scheme is removed, as is the trailing dot of the host
+//   - percent-encodings of unreserved characters are decoded, the other ones uppercased,
+//     and dot segments are removed from the path, as per RFC 3986 section 6.2.2
+//   - a root path is removed, since it is the same as an empty one
+//   - query parameters are sorted by name, keeping the order of the values of the same name, and empty ones are removed
+//   - tracking parameters are removed, if any is configured
+//   - the fragment is removed, if configured to, since it is not sent to servers but client side routers may rely on it
+//
+// The zero value normalizes urls without removing tracking parameters nor fragments.
+type URLNormalizer struct {
+	stripFragment bool
+	tracking      map[string]bool
+	trackingPrefs []string
+}
+
+// NewURLNormalizer returns a new URLNormalizer removing the tracking parameters and, if stripFragment is set, fragments.
+// Tracking parameters are matched ignoring case, names ending with * match every parameter starting with the rest of the name.
+func NewURLNormalizer(stripFragment bool, trackingParams []string) URLNormalizer {
+	n := URLNormalizer{
+		stripFragment: stripFragment,
+		tracking:      make(map[string]bool, len(trackingParams)),
+	}
+	for _, param := range trackingParams {
+		param = strings.ToLower(param)
+		if strings.HasSuffix(param, "*") {
+			n.trackingPrefs = append(n.trackingPrefs, strings.TrimSuffix(param, "*"))
+			continue
+		}
+		n.tracking[param] = true
+	}
+	return n
+}
+
+// Normalize returns the canonical form of the url.
+// Returns an error if the url can not be parsed.
+func (n URLNormalizer) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !hasScheme(rawURL) {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse url: %w", err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host, err = normalizeHost(u)
+	if err != nil {
+		return "", err
+	}
+	if u.Opaque == "" {
+		err = setEscapedPath(u, removeDotSegments(normalizeEscapes(u.EscapedPath())))
+		if err != nil {
+			return "", err
+		}
+	}
+	if u.Path == "/" && u.RawPath == "" {
+		u.Path = ""
+	}
+	u.RawQuery = n.normalizeQuery(u.RawQuery)
+	u.ForceQuery = false
+	if n.stripFragment {
+		u.Fragment, u.RawFragment = "", ""
+	}
+	return u.String(), nil
+}
+
+// hasScheme reports whether the url starts with a scheme, telling it apart from a host followed by a port.
+func hasScheme(rawURL string) bool {
+	i := strings.IndexByte(rawURL, ':')
+	if i <= 0 {
+		return false
+	}
+	for j, c := range rawURL[:i] {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case j > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-'):
+		default: // dots are valid in schemes, but only hosts have them in practice
+			return false
+		}
+	}
+	rest := rawURL[i+1:]
+	return !(rest != "" && '0' <= rest[0] && rest[0] <= '9') // a port, unless the url is made of the scheme only
+}
+
+// normalizeHost returns the host of the url lowercased, mapped as per UTS 46 and encoded with punycode,
+// without trailing dot and without the default port of the scheme.
+func normalizeHost(u *url.URL) (string, error) {
+	name, port := strings.TrimSuffix(u.Hostname(), "."), u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(name, ":") { // IPv6 addresses have no labels to encode
+		name = "[" + strings.ToLower(name) + "]"
+	} else {
+		var err error
+		name, err = toASCII(name)
+		if err != nil {
+			return "", fmt.Errorf("could not encode host: %w", err)
+		}
+	}
+	if port == "" {
+		return name, nil
+	}
+	return name + ":" + port, nil
+}
+
+// normalizeEscapes decodes the percent-encodings of unreserved characters, which mean the same once decoded,
+// and uppercases the hexadecimal digits of the other ones.
+func normalizeEscapes(escaped string) string {
+	if !strings.Contains(escaped, "%") {
+		return escaped
+	}
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] != '%' || i+2 >= len(escaped) || !isHex(escaped[i+1]) || !isHex(escaped[i+2]) {
+			b.WriteByte(escaped[i])
+			continue
+		}
+		c := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(escaped[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments removes the . and .. segments of the escaped path, see RFC 3986 section 5.2.4.
+func removeDotSegments(escaped string) string {
+	if !strings.Contains(escaped, ".") {
+		return escaped
+	}
+	segments := strings.Split(escaped, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 1 { // the first segment is the empty one before the leading slash
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		if last { // a path ending with a dot segment is a directory
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+// setEscapedPath sets the path of the url from its escaped form.
+// Returns an error if any.
+func setEscapedPath(u *url.URL, escaped string) error {
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return fmt.Errorf("could not unescape path: %w", err)
+	}
+	u.Path, u.RawPath = path, ""
+	if u.EscapedPath() != escaped { // keep the encoding only when it is not the default one, as url.Parse does
+		u.RawPath = escaped
+	}
+	return nil
+}
+
+// isUnreserved reports whether the character is unreserved in urls, see RFC 3986 section 2.3.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isHex reports whether the character is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of the hexadecimal digit.
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// normalizeQuery returns the query with the parameters sorted by name and without the empty and the tracking ones.
+// Parameters are kept as they are encoded, so that the ones servers read differently once decoded are never mixed up.
+func (n URLNormalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" || n.isTracking(queryName(param)) {
+			continue
+		}
+		params = append(params, param)
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return queryName(params[i]) < queryName(params[j])
+	})
+	return strings.Join(params, "&")
+}
+
+// isTracking reports whether the query parameter is a tracking one.
+func (n URLNormalizer) isTracking(name string) bool {
+	if name, err := url.QueryUnescape(name); err == nil {
+		name = strings.ToLower(name)
+		if n.tracking[name] {
+			return true
+		}
+		for _, prefix := range n.trackingPrefs {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queryName returns the name of the encoded query parameter.
+func queryName(param string) string {
+	if i := strings.IndexByte(param, '='); i >= 0 {
+		return param[:i]
+	}
+	return param
+}
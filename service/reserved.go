@@ -0,0 +1,69 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultReservedSlugs are the slugs that could be mistaken for pages of the service itself.
+var DefaultReservedSlugs = []string{
+	"about", "admin", "api", "app", "assets", "auth", "dashboard", "debug", "help", "login", "logout",
+	"r", "register", "settings", "signin", "signup", "static", "status", "url", "www",
+}
+
+// SlugFilter rejects the slugs that are reserved or contain a blocked word, ignoring case.
+// The zero value allows every slug.
+type SlugFilter struct {
+	reserved map[string]bool
+	blocked  []string
+}
+
+// NewSlugFilter returns a new SlugFilter rejecting the reserved slugs and the slugs containing any of the blocked words.
+func NewSlugFilter(reserved, blocked []string) SlugFilter {
+	f := SlugFilter{
+		reserved: make(map[string]bool, len(reserved)),
+		blocked:  make([]string, 0, len(blocked)),
+	}
+	for _, word := range reserved {
+		f.reserved[strings.ToLower(word)] = true
+	}
+	for _, word := range blocked {
+		f.blocked = append(f.blocked, strings.ToLower(word))
+	}
+	return f
+}
+
+// Allowed reports whether the slug is neither reserved nor contains a blocked word.
+func (f SlugFilter) Allowed(slug string) bool {
+	slug = strings.ToLower(slug)
+	if f.reserved[slug] {
+		return false
+	}
+	for _, word := range f.blocked {
+		if strings.Contains(slug, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadWords reads a list of words, one per line.
+// Blank lines and lines starting with # are skipped.
+// Returns an error if any.
+func ReadWords(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read words: %w", err)
+	}
+	return words, nil
+}
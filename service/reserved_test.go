@@ -0,0 +1,70 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlugFilter_Allowed(t *testing.T) {
+	t.Parallel()
+
+	filter := NewSlugFilter([]string{"admin", "API"}, []string{"Darn"})
+	tests := []struct {
+		name string
+		slug string
+		want bool
+	}{
+		{
+			name: "Happy Path - allowed",
+			slug: "pizza",
+			want: true,
+		},
+		{
+			name: "Happy Path - reserved word within the slug",
+			slug: "administrator",
+			want: true,
+		},
+		{
+			name: "Sad Path - reserved",
+			slug: "admin",
+			want: false,
+		},
+		{
+			name: "Sad Path - reserved ignoring case",
+			slug: "Api",
+			want: false,
+		},
+		{
+			name: "Sad Path - blocked",
+			slug: "darn",
+			want: false,
+		},
+		{
+			name: "Sad Path - blocked word within the slug",
+			slug: "xDARNx",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, filter.Allowed(tt.slug))
+		})
+	}
+}
+
+func TestSlugFilter_ZeroValue(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, SlugFilter{}.Allowed("admin"))
+}
+
+func TestReadWords(t *testing.T) {
+	t.Parallel()
+
+	words, err := ReadWords(strings.NewReader("# blocked words\ndarn\n\n  heck  \n#gosh\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"darn", "heck"}, words)
+}
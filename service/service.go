@@ -17,6 +17,38 @@ const (
 	ErrURLNotFound Error = `url not found`
 	// ErrInvalidSlug is returned when trying to use a not valid slug.
 	ErrInvalidSlug Error = `slug not valid`
+	// ErrURLExpired is returned when trying to get a shortened url past its expiration time.
+	ErrURLExpired Error = `url expired`
+	// ErrInvalidExpiration is returned when trying to shorten a url with a not valid ttl or expiration time.
+	ErrInvalidExpiration Error = `expiration not valid`
+	// ErrHitLimitReached is returned when trying to resolve a shortened url that reached its max hits.
+	ErrHitLimitReached Error = `hit limit reached`
+	// ErrInvalidMaxHits is returned when trying to shorten a url with a not valid max hits.
+	ErrInvalidMaxHits Error = `max hits not valid`
+	// ErrInvalidPassword is returned when trying to shorten a url with a password longer than bcrypt can hash.
+	ErrInvalidPassword Error = `password not valid`
+	// ErrPasswordRequired is returned when trying to resolve a password protected shortened url without a password.
+	ErrPasswordRequired Error = `password required`
+	// ErrWrongPassword is returned when trying to resolve a password protected shortened url with a wrong password.
+	ErrWrongPassword Error = `wrong password`
+	// ErrTooManyAttempts is returned when trying to resolve a password protected shortened url
+	// after too many wrong passwords.
+	ErrTooManyAttempts Error = `too many attempts`
+	// ErrInvalidRedirectStatus is returned when trying to shorten a url with a not valid redirect status.
+	ErrInvalidRedirectStatus Error = `redirect status not valid`
+	// ErrInvalidURL is returned when the url to shorten is malformed or points somewhere shortened urls must not redirect to.
+	ErrInvalidURL Error = `url not valid`
+	// ErrURLBlocked is returned when the url to shorten or to redirect to is in the blocklist.
+	ErrURLBlocked Error = `url blocked`
+	// ErrInvalidStatsQuery is returned when the time range or the interval of the statistics are not valid.
+	ErrInvalidStatsQuery Error = `stats query not valid`
+	// ErrInvalidExportQuery is returned when the time range of the clicks to export is not valid.
+	ErrInvalidExportQuery Error = `export query not valid`
+	// ErrBotForbidden is returned when a bot or a crawler tries to resolve a shortened url having max hits,
+	// which would either use up its hits or reveal the original url without consuming one.
+	ErrBotForbidden Error = `bots forbidden`
+	// ErrSlugReserved is returned when trying to add a shortened url with a reserved or blocked slug.
+	ErrSlugReserved Error = `slug reserved`
 )
 
 // Error represents an error returned by the repository.
@@ -31,6 +63,11 @@ func (e Error) Error() string {
 type Service interface {
 	Add(ctx context.Context, shortURL models.URLShortened) (models.URLShortened, error)
 	Get(ctx context.Context, slug string) (models.URLShortened, error)
-	Shorten(ctx context.Context, url string) (models.URLShortened, error)
+	Resolve(ctx context.Context, slug, password string) (models.URLShortened, error)
+	ResolveBot(ctx context.Context, slug, password string) (models.URLShortened, error)
+	Shorten(ctx context.Context, shortURL models.URLShortened) (models.URLShortened, error)
 	Delete(ctx context.Context, slug string) error
+	Stats(ctx context.Context, slug string, query StatsQuery) (models.ClickStats, error)
+	ExportClicks(ctx context.Context, slug string, query ExportQuery) (ClickIterator, error)
+	ExportAllClicks(ctx context.Context, query ExportQuery) (ClickIterator, error)
 }
@@ -60,17 +60,43 @@ func (mr *MockServiceMockRecorder) Get(ctx, slug interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockService)(nil).Get), ctx, slug)
 }
 
+// Resolve mocks base method
+func (m *MockService) Resolve(ctx context.Context, slug, password string) (models.URLShortened, error) {
+	ret := m.ctrl.Call(m, "Resolve", ctx, slug, password)
+	ret0, _ := ret[0].(models.URLShortened)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resolve indicates an expected call of Resolve
+func (mr *MockServiceMockRecorder) Resolve(ctx, slug, password interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resolve", reflect.TypeOf((*MockService)(nil).Resolve), ctx, slug, password)
+}
+
+// ResolveBot mocks base method
+func (m *MockService) ResolveBot(ctx context.Context, slug, password string) (models.URLShortened, error) {
+	ret := m.ctrl.Call(m, "ResolveBot", ctx, slug, password)
+	ret0, _ := ret[0].(models.URLShortened)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveBot indicates an expected call of ResolveBot
+func (mr *MockServiceMockRecorder) ResolveBot(ctx, slug, password interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveBot", reflect.TypeOf((*MockService)(nil).ResolveBot), ctx, slug, password)
+}
+
 // Shorten mocks base method
-func (m *MockService) Shorten(ctx context.Context, url string) (models.URLShortened, error) {
-	ret := m.ctrl.Call(m, "Shorten", ctx, url)
+func (m *MockService) Shorten(ctx context.Context, shortURL models.URLShortened) (models.URLShortened, error) {
+	ret := m.ctrl.Call(m, "Shorten", ctx, shortURL)
 	ret0, _ := ret[0].(models.URLShortened)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Shorten indicates an expected call of Shorten
-func (mr *MockServiceMockRecorder) Shorten(ctx, url interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockService)(nil).Shorten), ctx, url)
+func (mr *MockServiceMockRecorder) Shorten(ctx, shortURL interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shorten", reflect.TypeOf((*MockService)(nil).Shorten), ctx, shortURL)
 }
 
 // Delete mocks base method
@@ -84,3 +110,42 @@ func (m *MockService) Delete(ctx context.Context, slug string) error {
 func (mr *MockServiceMockRecorder) Delete(ctx, slug interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockService)(nil).Delete), ctx, slug)
 }
+
+// Stats mocks base method
+func (m *MockService) Stats(ctx context.Context, slug string, query StatsQuery) (models.ClickStats, error) {
+	ret := m.ctrl.Call(m, "Stats", ctx, slug, query)
+	ret0, _ := ret[0].(models.ClickStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockServiceMockRecorder) Stats(ctx, slug, query interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockService)(nil).Stats), ctx, slug, query)
+}
+
+// ExportClicks mocks base method
+func (m *MockService) ExportClicks(ctx context.Context, slug string, query ExportQuery) (ClickIterator, error) {
+	ret := m.ctrl.Call(m, "ExportClicks", ctx, slug, query)
+	ret0, _ := ret[0].(ClickIterator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportClicks indicates an expected call of ExportClicks
+func (mr *MockServiceMockRecorder) ExportClicks(ctx, slug, query interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportClicks", reflect.TypeOf((*MockService)(nil).ExportClicks), ctx, slug, query)
+}
+
+// ExportAllClicks mocks base method
+func (m *MockService) ExportAllClicks(ctx context.Context, query ExportQuery) (ClickIterator, error) {
+	ret := m.ctrl.Call(m, "ExportAllClicks", ctx, query)
+	ret0, _ := ret[0].(ClickIterator)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportAllClicks indicates an expected call of ExportAllClicks
+func (mr *MockServiceMockRecorder) ExportAllClicks(ctx, query interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportAllClicks", reflect.TypeOf((*MockService)(nil).ExportAllClicks), ctx, query)
+}
@@ -3,40 +3,84 @@
 package service
 
 import (
-	"math/rand"
-	"time"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
 )
 
 // Slugger defines the behaviour of a component capable of returning a slug.
 type Slugger interface {
-	Slug() string
+	Slug(ctx context.Context) (string, error)
 	Validate(string) bool
 }
 
+// URLSlugger defines the behaviour of a Slugger capable of deriving the slug from the url to shorten,
+// so that shortening the same url returns the same slug, whatever the instance doing it.
+// Each attempt returns a longer slug, to get past the slugs already in use by other urls.
+type URLSlugger interface {
+	Slugger
+	SlugFor(url string, attempt int) string
+}
+
 // FixedLenSlugger is a slugger implementation that returns slugs having fixed length.
+// Letters are drawn from a cryptographically secure source, so that slugs can not be predicted from the previous ones.
 type FixedLenSlugger struct {
 	dictionary []rune
+	letters    map[rune]bool
 	length     int
+	random     io.Reader
 }
 
-// NewFixedLenSlugger returns a new FixedLenSlugger.
+// NewFixedLenSlugger returns a new FixedLenSlugger using lowercase letters.
 func NewFixedLenSlugger(l int) FixedLenSlugger {
-	rand.Seed(time.Now().UnixNano())
+	s, _ := NewAlphabetSlugger(AlphabetLowercase, l) // the lowercase alphabet is always valid
+	return s
+}
+
+// NewAlphabetSlugger returns a new FixedLenSlugger using the letters of the alphabet.
+// Returns an error if the alphabet is not valid.
+func NewAlphabetSlugger(alphabet string, l int) (FixedLenSlugger, error) {
+	set, err := letters(alphabet)
+	if err != nil {
+		return FixedLenSlugger{}, err
+	}
 	return FixedLenSlugger{
-		dictionary: []rune("abcdefghijklmnopqrstuvwxyz"),
+		dictionary: []rune(alphabet),
+		letters:    set,
 		length:     l,
-	}
+		random:     rand.Reader,
+	}, nil
 }
 
 // Slug returns a slug having fixed length.
-func (s FixedLenSlugger) Slug() string {
-	b := make([]rune, s.length)
-	for i := range b {
-		b[i] = s.dictionary[rand.Intn(len(s.dictionary))]
+// Every letter is equally likely: random bytes that would favour the first letters of the alphabet are discarded.
+// Returns an error if any.
+func (s FixedLenSlugger) Slug(context.Context) (string, error) {
+	n := len(s.dictionary)
+	limit := 256 - 256%n                     // the largest multiple of n bytes can reach, alphabets are at most 66 letters long
+	buf := make([]byte, s.length+s.length/2) // a few spare bytes for the discarded ones
+	b := make([]rune, 0, s.length)
+	for len(b) < s.length {
+		_, err := io.ReadFull(s.random, buf)
+		if err != nil {
+			return "", fmt.Errorf("could not read random bytes: %w", err)
+		}
+		for _, r := range buf {
+			if int(r) >= limit {
+				continue
+			}
+			b = append(b, s.dictionary[int(r)%n])
+			if len(b) == s.length {
+				break
+			}
+		}
 	}
-	return string(b)
+	return string(b), nil
 }
 
+// Validate reports whether the slug has the fixed length and is made of letters of the alphabet only.
 func (s FixedLenSlugger) Validate(slug string) bool {
 	if s.length == 0 {
 		return false
@@ -45,9 +89,104 @@ func (s FixedLenSlugger) Validate(slug string) bool {
 		return false
 	}
 	for _, c := range slug {
-		if !lettersMap[c] {
+		if !s.letters[c] {
 			return false
 		}
 	}
 	return true
 }
+
+// CollisionObserver defines the behaviour of a Slugger that adapts to how often its slugs are already in use.
+type CollisionObserver interface {
+	Observe(collided bool)
+}
+
+const (
+	// collisionWindow is the number of generated slugs over which the collision rate is measured.
+	collisionWindow = 100
+	// maxCollisionRate is the collision rate, in percent, above which the keyspace is considered saturated.
+	maxCollisionRate = 10
+)
+
+// GrowingSlugger is a Slugger that returns slugs of the same length until too many of them are already in use,
+// then it makes them one letter longer, up to a maximum length.
+// Slugs of any length between the minimum and the current one are valid.
+// It is safe for concurrent use.
+type GrowingSlugger struct {
+	alphabet string
+	minLen   int
+	maxLen   int
+
+	mu                     sync.RWMutex
+	current                FixedLenSlugger
+	observations, collided int
+}
+
+// NewGrowingSlugger returns a new GrowingSlugger using the letters of the alphabet,
+// starting with slugs of minLen letters and growing up to maxLen letters.
+// Returns an error if the alphabet or the lengths are not valid.
+func NewGrowingSlugger(alphabet string, minLen, maxLen int) (*GrowingSlugger, error) {
+	if minLen <= 0 || maxLen < minLen {
+		return nil, fmt.Errorf("slug lengths %d-%d not valid", minLen, maxLen)
+	}
+	current, err := NewAlphabetSlugger(alphabet, minLen)
+	if err != nil {
+		return nil, err
+	}
+	return &GrowingSlugger{
+		alphabet: alphabet,
+		minLen:   minLen,
+		maxLen:   maxLen,
+		current:  current,
+	}, nil
+}
+
+// Slug returns a slug having the current length.
+func (s *GrowingSlugger) Slug(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.Slug(ctx)
+}
+
+// Validate reports whether the slug has a length between the minimum and the current one
+// and is made of letters of the alphabet only.
+func (s *GrowingSlugger) Validate(slug string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(slug) < s.minLen || len(slug) > s.current.length {
+		return false
+	}
+	for _, c := range slug {
+		if !s.current.letters[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the current length of the slugs.
+func (s *GrowingSlugger) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current.length
+}
+
+// Observe records whether a generated slug was already in use,
+// growing the slugs once the collision rate over the last window is too high.
+func (s *GrowingSlugger) Observe(collided bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observations++
+	if collided {
+		s.collided++
+	}
+	// the window is bound to be over the rate as soon as enough collisions are seen, no need to wait for it to end
+	saturated := s.collided*100 >= collisionWindow*maxCollisionRate
+	if !saturated && s.observations < collisionWindow {
+		return
+	}
+	if saturated && s.current.length < s.maxLen {
+		s.current.length++
+	}
+	s.observations, s.collided = 0, 0
+}
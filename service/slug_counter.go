@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+
+	"github.com/indiependente/shrtnr/repository"
+)
+
+const (
+	// slugCounter is the name of the counter numbering the generated slugs.
+	slugCounter = "slugs"
+	// feistelRounds is the number of rounds of the Feistel network obfuscating the counter.
+	feistelRounds = 4
+)
+
+// CounterSlugger is a slugger implementation that numbers slugs with a persistent counter,
+// so that they are unique by construction.
+// Numbers are shuffled by a keyed permutation before being encoded with the alphabet,
+// so that consecutive slugs do not look alike.
+// Slugs have the minimum length until all of them are used, then they grow one letter at a time.
+type CounterSlugger struct {
+	counter    repository.Counter
+	dictionary []rune
+	letters    map[rune]bool
+	minLen     int
+	key        uint64
+}
+
+// NewCounterSlugger returns a new CounterSlugger using the letters of the alphabet
+// and the key to shuffle the numbers handed out by the counter.
+// Returns an error if the alphabet or the minimum length are not valid.
+func NewCounterSlugger(counter repository.Counter, alphabet string, minLen int, key uint64) (CounterSlugger, error) {
+	if minLen <= 0 {
+		return CounterSlugger{}, fmt.Errorf("slug length %d not valid", minLen)
+	}
+	set, err := letters(alphabet)
+	if err != nil {
+		return CounterSlugger{}, err
+	}
+	return CounterSlugger{
+		counter:    counter,
+		dictionary: []rune(alphabet),
+		letters:    set,
+		minLen:     minLen,
+		key:        key,
+	}, nil
+}
+
+// Slug returns the slug of the next number handed out by the counter.
+// Returns an error if any.
+func (s CounterSlugger) Slug(ctx context.Context) (string, error) {
+	n, err := s.counter.Next(ctx, slugCounter)
+	if err != nil {
+		return "", fmt.Errorf("could not count slug: %w", err)
+	}
+	return s.encode(n - 1), nil // counters start from 1
+}
+
+// Validate reports whether the slug has at least the minimum length and is made of letters of the alphabet only.
+func (s CounterSlugger) Validate(slug string) bool {
+	if len(slug) < s.minLen {
+		return false
+	}
+	for _, c := range slug {
+		if !s.letters[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// encode returns the slug of the n-th number.
+// Numbers are split in blocks, one per slug length, each number is shuffled within its block
+// so that the slugs of different blocks can never be the same.
+func (s CounterSlugger) encode(n uint64) string {
+	base := uint64(len(s.dictionary))
+	length := s.minLen
+	size, ok := pow(base, length)
+	for ok && n >= size {
+		n -= size
+		length++
+		size, ok = pow(base, length)
+	}
+	if !ok {
+		size = 0 // the block is larger than any uint64, so every number fits
+	}
+	x := shuffle(n, size, s.key^uint64(length))
+	b := make([]rune, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = s.dictionary[x%base]
+		x /= base
+	}
+	return string(b)
+}
+
+// pow returns base^exp, reporting whether it fits in a uint64.
+func pow(base uint64, exp int) (uint64, bool) {
+	result := uint64(1)
+	for i := 0; i < exp; i++ {
+		hi, lo := bits.Mul64(result, base)
+		if hi != 0 {
+			return 0, false
+		}
+		result = lo
+	}
+	return result, true
+}
+
+// shuffle maps n to another number of [0, size) through a keyed permutation, so that it is reversible and collision free.
+// The permutation is a balanced Feistel network over the smallest even number of bits fitting size,
+// cycle walking the results out of range back into it.
+// A zero size stands for the whole uint64 range.
+func shuffle(n, size, key uint64) uint64 {
+	width := 64
+	if size != 0 {
+		width = bits.Len64(size - 1)
+	}
+	half := uint((width + 1) / 2)
+	x := feistel(n, half, key)
+	for size != 0 && x >= size {
+		x = feistel(x, half, key)
+	}
+	return x
+}
+
+// feistel runs the Feistel network over a number made of two halves of the given number of bits.
+func feistel(x uint64, half uint, key uint64) uint64 {
+	mask := uint64(1)<<half - 1
+	l, r := x>>half&mask, x&mask
+	for round := uint64(0); round < feistelRounds; round++ {
+		l, r = r, l^(mix(r^key^round<<56)&mask)
+	}
+	return l<<half | r
+}
+
+// mix scrambles the bits of x, it is the finalizer of the SplitMix64 generator.
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterSlugger_SlugsAreUnique(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	slugger, err := NewCounterSlugger(repository.NewMemoryCounter(), "abc", 2, 42)
+	require.NoError(t, err)
+
+	// 9 slugs of 2 letters, then 27 of 3 letters, then 4 letters
+	seen := map[string]bool{}
+	lengths := map[int]int{}
+	for i := 0; i < 9+27+1; i++ {
+		slug, err := slugger.Slug(ctx)
+		require.NoError(t, err)
+		require.True(t, slugger.Validate(slug), "slug %q not valid", slug)
+		require.False(t, seen[slug], "slug %q handed out twice", slug)
+		seen[slug] = true
+		lengths[len(slug)]++
+	}
+	require.Equal(t, map[int]int{2: 9, 3: 27, 4: 1}, lengths)
+}
+
+func TestCounterSlugger_SlugsAreShuffled(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	slugger, err := NewCounterSlugger(repository.NewMemoryCounter(), AlphabetBase62, 6, 42)
+	require.NoError(t, err)
+	other, err := NewCounterSlugger(repository.NewMemoryCounter(), AlphabetBase62, 6, 43)
+	require.NoError(t, err)
+
+	first, err := slugger.Slug(ctx)
+	require.NoError(t, err)
+	second, err := slugger.Slug(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, "000000", first)
+	require.NotEqual(t, first[:5], second[:5], "consecutive slugs should not look alike")
+
+	otherFirst, err := other.Slug(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, first, otherFirst, "different keys should shuffle differently")
+}
+
+func TestCounterSlugger_LargeCounters(t *testing.T) {
+	t.Parallel()
+	slugger, err := NewCounterSlugger(repository.NewMemoryCounter(), AlphabetBase62, 10, 42)
+	require.NoError(t, err)
+	// the blocks of 11 letters and more do not fit in a uint64
+	for _, n := range []uint64{1 << 60, 1<<64 - 1} {
+		slug := slugger.encode(n)
+		require.True(t, slugger.Validate(slug), "slug %q not valid", slug)
+	}
+}
+
+func TestCounterSlugger_CounterError(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	counter := repository.NewMockCounter(ctrl)
+	counter.EXPECT().Next(gomock.Any(), slugCounter).Return(uint64(0), errors.New("unexpected error"))
+	slugger, err := NewCounterSlugger(counter, AlphabetBase62, 6, 42)
+	require.NoError(t, err)
+
+	_, err = slugger.Slug(context.Background())
+	require.Error(t, err)
+}
+
+func TestShuffle_IsAPermutation(t *testing.T) {
+	t.Parallel()
+	for _, size := range []uint64{1, 2, 3, 10, 64, 1000} {
+		seen := make([]bool, size)
+		for n := uint64(0); n < size; n++ {
+			x := shuffle(n, size, 42)
+			require.Less(t, x, size)
+			require.False(t, seen[x], "%d mapped twice in [0, %d)", x, size)
+			seen[x] = true
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// HashSlugger is a slugger implementation that derives slugs from a salted hash of the url,
+// encoded with the alphabet and truncated to the minimum length.
+// Urls whose truncated hashes are the same get a longer prefix of their hash on the next attempts.
+// The salt keeps the slug of a url from being guessed.
+type HashSlugger struct {
+	random     FixedLenSlugger
+	dictionary []rune
+	salt       []byte
+	minLen     int
+}
+
+// NewHashSlugger returns a new HashSlugger using the letters of the alphabet and the salt.
+// Returns an error if the alphabet or the minimum length are not valid.
+func NewHashSlugger(alphabet string, minLen int, salt string) (HashSlugger, error) {
+	if minLen <= 0 {
+		return HashSlugger{}, fmt.Errorf("slug length %d not valid", minLen)
+	}
+	random, err := NewAlphabetSlugger(alphabet, minLen)
+	if err != nil {
+		return HashSlugger{}, err
+	}
+	return HashSlugger{
+		random:     random,
+		dictionary: []rune(alphabet),
+		salt:       []byte(salt),
+		minLen:     minLen,
+	}, nil
+}
+
+// Slug returns a random slug having the minimum length, for the shortened urls that must not be shared.
+func (s HashSlugger) Slug(ctx context.Context) (string, error) {
+	return s.random.Slug(ctx)
+}
+
+// SlugFor returns the slug of the url, made of the first minimum length plus attempt letters of its hash.
+// Once the hash is used up, longer slugs are made hashing the hash again.
+func (s HashSlugger) SlugFor(url string, attempt int) string {
+	length := s.minLen + attempt
+	b := make([]rune, 0, length)
+	digest := s.digest([]byte(url))
+	for {
+		b = append(b, s.encode(digest)...)
+		if len(b) >= length {
+			return string(b[:length])
+		}
+		digest = s.digest(digest)
+	}
+}
+
+// Validate reports whether the slug has at least the minimum length and is made of letters of the alphabet only.
+func (s HashSlugger) Validate(slug string) bool {
+	if len(slug) < s.minLen {
+		return false
+	}
+	for _, c := range slug {
+		if !s.random.letters[c] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s HashSlugger) digest(data []byte) []byte {
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write(data) // nolint: errcheck
+	return mac.Sum(nil)
+}
+
+// encode returns the digest written in base of the alphabet.
+// Only the letters fully covered by the bits of the digest are returned, so that they are evenly distributed.
+func (s HashSlugger) encode(digest []byte) []rune {
+	base := big.NewInt(int64(len(s.dictionary)))
+	letters := int(float64(len(digest)*8) / math.Log2(float64(len(s.dictionary))))
+	n := new(big.Int).SetBytes(digest)
+	mod := new(big.Int)
+	b := make([]rune, letters)
+	for i := range b {
+		n.DivMod(n, base, mod)
+		b[i] = s.dictionary[mod.Int64()]
+	}
+	return b
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashSlugger_SlugFor(t *testing.T) {
+	t.Parallel()
+	slugger, err := NewHashSlugger(AlphabetBase62, 6, "pepper")
+	require.NoError(t, err)
+	salted, err := NewHashSlugger(AlphabetBase62, 6, "salt")
+	require.NoError(t, err)
+
+	slug := slugger.SlugFor("http://indiependente.dev", 0)
+	require.Len(t, slug, 6)
+	require.True(t, slugger.Validate(slug))
+	require.Equal(t, slug, slugger.SlugFor("http://indiependente.dev", 0), "the same url gets the same slug")
+	require.NotEqual(t, slug, slugger.SlugFor("http://indiependente.dev/pizza", 0))
+	require.NotEqual(t, slug, salted.SlugFor("http://indiependente.dev", 0), "the salt changes the slugs")
+
+	// every attempt extends the previous slug
+	for attempt := 1; attempt < 100; attempt++ {
+		longer := slugger.SlugFor("http://indiependente.dev", attempt)
+		require.Len(t, longer, 6+attempt)
+		require.Equal(t, slug, longer[:len(slug)])
+		require.True(t, slugger.Validate(longer))
+		slug = longer
+	}
+}
+
+func TestHashSlugger_LettersAreEvenlyDistributed(t *testing.T) {
+	t.Parallel()
+	slugger, err := NewHashSlugger("ab", 1, "pepper")
+	require.NoError(t, err)
+
+	const urls = 2000
+	counts := map[string]int{}
+	for i := 0; i < urls; i++ {
+		counts[slugger.SlugFor(fmt.Sprintf("http://indiependente.dev/%d", i), 0)]++
+	}
+	require.InDelta(t, urls/2, counts["a"], urls/10)
+	require.InDelta(t, urls/2, counts["b"], urls/10)
+}
+
+func TestURLService_ShortenWithHashSluggerIsIdempotent(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	slugger, err := NewHashSlugger(AlphabetBase62, 1, "pepper")
+	require.NoError(t, err)
+
+	// two instances sharing the storage
+	store := repository.NewMemoryURLStorer()
+	hits := NewHitAggregator(store, 0, 0)
+	first := NewURLService(store, slugger, hits)
+	second := NewURLService(store, slugger, hits)
+
+	short, err := first.Add(ctx, models.URLShortened{URL: "http://indiependente.dev"})
+	require.NoError(t, err)
+	require.Equal(t, slugger.SlugFor("http://indiependente.dev", 0), short.Slug)
+	again, err := second.Add(ctx, models.URLShortened{URL: "http://indiependente.dev"})
+	require.NoError(t, err)
+	require.Equal(t, short, again)
+
+	// a single letter keyspace makes urls share prefixes, their slugs get longer instead
+	slugs := map[string]bool{short.Slug: true}
+	for i := 0; i < 20; i++ {
+		short, err := second.Shorten(ctx, models.URLShortened{URL: fmt.Sprintf("http://indiependente.dev/%d", i)})
+		require.NoError(t, err)
+		require.False(t, slugs[short.Slug], "slug %q handed out twice", short.Slug)
+		slugs[short.Slug] = true
+	}
+}
@@ -5,6 +5,7 @@
 package service
 
 import (
+	context "context"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -33,15 +34,16 @@ func (m *MockSlugger) EXPECT() *MockSluggerMockRecorder {
 }
 
 // Slug mocks base method
-func (m *MockSlugger) Slug() string {
-	ret := m.ctrl.Call(m, "Slug")
+func (m *MockSlugger) Slug(ctx context.Context) (string, error) {
+	ret := m.ctrl.Call(m, "Slug", ctx)
 	ret0, _ := ret[0].(string)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Slug indicates an expected call of Slug
-func (mr *MockSluggerMockRecorder) Slug() *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Slug", reflect.TypeOf((*MockSlugger)(nil).Slug))
+func (mr *MockSluggerMockRecorder) Slug(ctx interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Slug", reflect.TypeOf((*MockSlugger)(nil).Slug), ctx)
 }
 
 // Validate mocks base method
@@ -55,3 +57,96 @@ func (m *MockSlugger) Validate(arg0 string) bool {
 func (mr *MockSluggerMockRecorder) Validate(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockSlugger)(nil).Validate), arg0)
 }
+
+// MockCollisionObserver is a mock of CollisionObserver interface
+type MockCollisionObserver struct {
+	ctrl     *gomock.Controller
+	recorder *MockCollisionObserverMockRecorder
+}
+
+// MockCollisionObserverMockRecorder is the mock recorder for MockCollisionObserver
+type MockCollisionObserverMockRecorder struct {
+	mock *MockCollisionObserver
+}
+
+// NewMockCollisionObserver creates a new mock instance
+func NewMockCollisionObserver(ctrl *gomock.Controller) *MockCollisionObserver {
+	mock := &MockCollisionObserver{ctrl: ctrl}
+	mock.recorder = &MockCollisionObserverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockCollisionObserver) EXPECT() *MockCollisionObserverMockRecorder {
+	return m.recorder
+}
+
+// Observe mocks base method
+func (m *MockCollisionObserver) Observe(collided bool) {
+	m.ctrl.Call(m, "Observe", collided)
+}
+
+// Observe indicates an expected call of Observe
+func (mr *MockCollisionObserverMockRecorder) Observe(collided interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Observe", reflect.TypeOf((*MockCollisionObserver)(nil).Observe), collided)
+}
+
+// MockURLSlugger is a mock of URLSlugger interface
+type MockURLSlugger struct {
+	ctrl     *gomock.Controller
+	recorder *MockURLSluggerMockRecorder
+}
+
+// MockURLSluggerMockRecorder is the mock recorder for MockURLSlugger
+type MockURLSluggerMockRecorder struct {
+	mock *MockURLSlugger
+}
+
+// NewMockURLSlugger creates a new mock instance
+func NewMockURLSlugger(ctrl *gomock.Controller) *MockURLSlugger {
+	mock := &MockURLSlugger{ctrl: ctrl}
+	mock.recorder = &MockURLSluggerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockURLSlugger) EXPECT() *MockURLSluggerMockRecorder {
+	return m.recorder
+}
+
+// Slug mocks base method
+func (m *MockURLSlugger) Slug(ctx context.Context) (string, error) {
+	ret := m.ctrl.Call(m, "Slug", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Slug indicates an expected call of Slug
+func (mr *MockURLSluggerMockRecorder) Slug(ctx interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Slug", reflect.TypeOf((*MockURLSlugger)(nil).Slug), ctx)
+}
+
+// Validate mocks base method
+func (m *MockURLSlugger) Validate(arg0 string) bool {
+	ret := m.ctrl.Call(m, "Validate", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate
+func (mr *MockURLSluggerMockRecorder) Validate(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockURLSlugger)(nil).Validate), arg0)
+}
+
+// SlugFor mocks base method
+func (m *MockURLSlugger) SlugFor(url string, attempt int) string {
+	ret := m.ctrl.Call(m, "SlugFor", url, attempt)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// SlugFor indicates an expected call of SlugFor
+func (mr *MockURLSluggerMockRecorder) SlugFor(url, attempt interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SlugFor", reflect.TypeOf((*MockURLSlugger)(nil).SlugFor), url, attempt)
+}
@@ -0,0 +1,43 @@
+package service
+
+import "sync/atomic"
+
+const (
+	// maxSlugAttempts is the number of generated slugs tried before giving up on adding a shortened url.
+	maxSlugAttempts = 5
+)
+
+// SlugStats reports how often generated slugs collide with the ones already in use.
+type SlugStats struct {
+	// Generated is the number of shortened urls added with a generated slug.
+	Generated uint64 `json:"generated"`
+	// Retries is the number of generated slugs that were already in use and had to be generated again.
+	Retries uint64 `json:"retries"`
+	// Exhausted is the number of shortened urls that could not be added within maxSlugAttempts.
+	Exhausted uint64 `json:"exhausted"`
+}
+
+// slugStats collects the SlugStats, it is safe for concurrent use.
+type slugStats struct {
+	generatedCount, retries, exhaustedCount uint64
+}
+
+// generated records a shortened url added with a generated slug at the given attempt.
+func (s *slugStats) generated(attempt int) {
+	atomic.AddUint64(&s.generatedCount, 1)
+	atomic.AddUint64(&s.retries, uint64(attempt-1))
+}
+
+// exhausted records a shortened url that could not be added after the given attempts.
+func (s *slugStats) exhausted(attempts int) {
+	atomic.AddUint64(&s.exhaustedCount, 1)
+	atomic.AddUint64(&s.retries, uint64(attempts-1))
+}
+
+func (s *slugStats) stats() SlugStats {
+	return SlugStats{
+		Generated: atomic.LoadUint64(&s.generatedCount),
+		Retries:   atomic.LoadUint64(&s.retries),
+		Exhausted: atomic.LoadUint64(&s.exhaustedCount),
+	}
+}
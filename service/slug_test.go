@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAlphabetSlugger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		alphabet string
+		wanterr  bool
+	}{
+		{
+			name:     "Happy Path - base62",
+			alphabet: AlphabetBase62,
+			wanterr:  false,
+		},
+		{
+			name:     "Happy Path - base58",
+			alphabet: AlphabetBase58,
+			wanterr:  false,
+		},
+		{
+			name:     "Happy Path - custom",
+			alphabet: "0123456789abcdef",
+			wanterr:  false,
+		},
+		{
+			name:     "Sad Path - empty",
+			alphabet: "",
+			wanterr:  true,
+		},
+		{
+			name:     "Sad Path - single letter",
+			alphabet: "a",
+			wanterr:  true,
+		},
+		{
+			name:     "Sad Path - repeated letter",
+			alphabet: "abca",
+			wanterr:  true,
+		},
+		{
+			name:     "Sad Path - not url safe",
+			alphabet: "ab/c",
+			wanterr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAlphabetSlugger(tt.alphabet, 5)
+			require.Equal(t, tt.wanterr, err != nil)
+		})
+	}
+}
+
+func TestFixedLenSlugger_Validate(t *testing.T) {
+	t.Parallel()
+	base58, err := NewAlphabetSlugger(AlphabetBase58, 5)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		slugger FixedLenSlugger
+		slug    string
+		want    bool
+	}{
+		{
+			name:    "Happy Path - lowercase",
+			slugger: NewFixedLenSlugger(5),
+			slug:    "pizza",
+			want:    true,
+		},
+		{
+			name:    "Happy Path - base58",
+			slugger: base58,
+			slug:    "Pz4aK",
+			want:    true,
+		},
+		{
+			name:    "Sad Path - uppercase with lowercase alphabet",
+			slugger: NewFixedLenSlugger(5),
+			slug:    "Pizza",
+			want:    false,
+		},
+		{
+			name:    "Sad Path - ambiguous letter with base58 alphabet",
+			slugger: base58,
+			slug:    "P0zza",
+			want:    false,
+		},
+		{
+			name:    "Sad Path - wrong length",
+			slugger: base58,
+			slug:    "Pz4",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.slugger.Validate(tt.slug))
+		})
+	}
+}
+
+func TestFixedLenSlugger_SlugIsValid(t *testing.T) {
+	t.Parallel()
+	for _, alphabet := range []string{AlphabetLowercase, AlphabetBase62, AlphabetBase58, "xyz"} {
+		slugger, err := NewAlphabetSlugger(alphabet, 7)
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			slug, err := slugger.Slug(context.Background())
+			require.NoError(t, err)
+			require.True(t, slugger.Validate(slug), "slug %q not valid for alphabet %q", slug, alphabet)
+		}
+	}
+}
+
+func TestGrowingSlugger_Observe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		collisions int
+		wantLen    int
+	}{
+		{
+			name:       "Happy Path - few collisions keep the length",
+			collisions: collisionWindow*maxCollisionRate/100 - 1,
+			wantLen:    5,
+		},
+		{
+			name:       "Happy Path - too many collisions grow the length",
+			collisions: collisionWindow * maxCollisionRate / 100,
+			wantLen:    6,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			slugger, err := NewGrowingSlugger(AlphabetBase58, 5, 7)
+			require.NoError(t, err)
+			for i := 0; i < collisionWindow; i++ {
+				slugger.Observe(i < tt.collisions)
+			}
+			require.Equal(t, tt.wantLen, slugger.Len())
+			slug, err := slugger.Slug(context.Background())
+			require.NoError(t, err)
+			require.Len(t, slug, tt.wantLen)
+		})
+	}
+}
+
+func TestGrowingSlugger_GrowsUpToMaxLen(t *testing.T) {
+	t.Parallel()
+	slugger, err := NewGrowingSlugger(AlphabetLowercase, 2, 3)
+	require.NoError(t, err)
+	for i := 0; i < 3*collisionWindow; i++ {
+		slugger.Observe(true)
+	}
+	require.Equal(t, 3, slugger.Len())
+	require.True(t, slugger.Validate("ab"), "slugs generated before growing stay valid")
+	require.True(t, slugger.Validate("abc"))
+	require.False(t, slugger.Validate("a"))
+	require.False(t, slugger.Validate("abcd"))
+}
+
+func TestNewGrowingSlugger(t *testing.T) {
+	t.Parallel()
+	_, err := NewGrowingSlugger(AlphabetLowercase, 0, 3)
+	require.Error(t, err)
+	_, err = NewGrowingSlugger(AlphabetLowercase, 4, 3)
+	require.Error(t, err)
+	_, err = NewGrowingSlugger("a", 4, 5)
+	require.Error(t, err)
+}
+
+func TestFixedLenSlugger_SlugDiscardsBiasedBytes(t *testing.T) {
+	t.Parallel()
+	slugger, err := NewAlphabetSlugger(AlphabetBase62, 3)
+	require.NoError(t, err)
+	// 62*4 = 248 is where the bytes would start favouring the first letters again
+	slugger.random = bytes.NewReader([]byte{248, 255, 0, 61, 249, 62, 0, 0, 0})
+
+	slug, err := slugger.Slug(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "0z0", slug)
+}
+
+func TestFixedLenSlugger_SlugRandomError(t *testing.T) {
+	t.Parallel()
+	slugger, err := NewAlphabetSlugger(AlphabetBase62, 3)
+	require.NoError(t, err)
+	slugger.random = bytes.NewReader(nil)
+
+	_, err = slugger.Slug(context.Background())
+	require.Error(t, err)
+}
+
+func TestFixedLenSlugger_SlugIsUniform(t *testing.T) {
+	t.Parallel()
+	const slugs = 20000
+
+	for _, alphabet := range []string{AlphabetLowercase, AlphabetBase62, AlphabetBase58, "ab"} {
+		alphabet := alphabet
+		t.Run(alphabet, func(t *testing.T) {
+			t.Parallel()
+			const length = 4
+			slugger, err := NewAlphabetSlugger(alphabet, length)
+			require.NoError(t, err)
+			counts := make([]map[rune]int, length) // letter counts per position
+			for i := range counts {
+				counts[i] = map[rune]int{}
+			}
+			for i := 0; i < slugs; i++ {
+				slug, err := slugger.Slug(context.Background())
+				require.NoError(t, err)
+				for pos, c := range slug {
+					counts[pos][c]++
+				}
+			}
+			letters := len(alphabet)
+			for pos := range counts {
+				chi2 := chiSquare(counts[pos], alphabet, slugs)
+				require.Less(t, chi2, chiSquareCritical(letters-1),
+					"letters at position %d are not uniform: chi square %f", pos, chi2)
+			}
+		})
+	}
+}
+
+// chiSquare returns the chi square statistic of the letter counts against the uniform distribution.
+func chiSquare(counts map[rune]int, alphabet string, total int) float64 {
+	expected := float64(total) / float64(len(alphabet))
+	var chi2 float64
+	for _, c := range alphabet {
+		d := float64(counts[c]) - expected
+		chi2 += d * d / expected
+	}
+	return chi2
+}
+
+// chiSquareCritical returns the chi square value exceeded with probability one in a million
+// by a uniform distribution with the degrees of freedom, using the Wilson-Hilferty approximation.
+// Such a low probability keeps the test from failing by chance, while still catching any real bias.
+func chiSquareCritical(df int) float64 {
+	const z = 4.753 // the standard normal quantile of 1 - 1e-6
+	k := float64(df)
+	h := 2 / (9 * k)
+	return k * math.Pow(1-h+z*math.Sqrt(h), 3)
+}
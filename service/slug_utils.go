@@ -1,32 +1,38 @@
 package service
 
-var (
-	lettersMap = map[rune]bool{
-		'a': true,
-		'b': true,
-		'c': true,
-		'd': true,
-		'e': true,
-		'f': true,
-		'g': true,
-		'h': true,
-		'i': true,
-		'j': true,
-		'k': true,
-		'l': true,
-		'm': true,
-		'n': true,
-		'o': true,
-		'p': true,
-		'q': true,
-		'r': true,
-		's': true,
-		't': true,
-		'u': true,
-		'v': true,
-		'w': true,
-		'x': true,
-		'y': true,
-		'z': true,
-	}
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// AlphabetLowercase is made of the lowercase latin letters.
+	AlphabetLowercase = "abcdefghijklmnopqrstuvwxyz"
+	// AlphabetBase62 is made of digits, uppercase and lowercase latin letters.
+	AlphabetBase62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// AlphabetBase58 is AlphabetBase62 without the characters easily mistaken for one another: 0, O, I and l.
+	AlphabetBase58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	// urlSafe is the set of characters that can be used in a url path without escaping.
+	urlSafe = AlphabetBase62 + "-._~"
 )
+
+// letters returns the set of letters of the alphabet.
+// Returns an error if the alphabet has less than two letters, repeats a letter
+// or has letters that can not be used in a url path without escaping.
+func letters(alphabet string) (map[rune]bool, error) {
+	set := map[rune]bool{}
+	for _, c := range alphabet {
+		if !strings.ContainsRune(urlSafe, c) {
+			return nil, fmt.Errorf("alphabet letter %q is not url safe", c)
+		}
+		if set[c] {
+			return nil, fmt.Errorf("alphabet letter %q is repeated", c)
+		}
+		set[c] = true
+	}
+	if len(set) < 2 {
+		return nil, fmt.Errorf("alphabet %q is too short", alphabet)
+	}
+	return set, nil
+}
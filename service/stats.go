@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+)
+
+const (
+	// maxStatsBuckets is the maximum number of buckets of the statistics, to keep responses small.
+	maxStatsBuckets = 1000
+	// topTallies is the number of most frequent values returned for each tally of the statistics.
+	topTallies = 10
+)
+
+// defaultStatsRanges are the time ranges of the statistics when no start is requested, per interval.
+var defaultStatsRanges = map[models.StatsInterval]time.Duration{
+	models.IntervalHour: 24 * time.Hour,
+	models.IntervalDay:  30 * 24 * time.Hour,
+	models.IntervalWeek: 12 * 7 * 24 * time.Hour,
+}
+
+// StatsQuery selects the time range [From, To) of the statistics and the interval of their buckets.
+// Zero values stand for the defaults: daily buckets, up to now, over a range depending on the interval.
+type StatsQuery struct {
+	From, To time.Time
+	Interval models.StatsInterval
+}
+
+// Stats returns the statistics of the clicks on the shortened url of the slug.
+// Buckets cover the whole time range, the ones without clicks included, so that they can be charted as they are.
+// Unique visitors are approximate, ever and per day, since only sketches of them are kept.
+// Statistics of expired shortened urls are still available.
+// Returns an error if any.
+func (usvc URLService) Stats(ctx context.Context, slug string, query StatsQuery) (models.ClickStats, error) {
+	if usvc.clicks == nil {
+		return models.ClickStats{}, fmt.Errorf("could not get stats: clicks are not stored")
+	}
+	url, err := usvc.lookup(ctx, slug)
+	if err != nil {
+		return models.ClickStats{}, err
+	}
+	query, err = usvc.statsQuery(query)
+	if err != nil {
+		return models.ClickStats{}, err
+	}
+	stats, err := usvc.clicks.ClickStats(ctx, repository.ClickQuery{
+		Slug: url.Slug,
+		From: query.From,
+		To:   query.To,
+	}, query.Interval, topTallies)
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("could not get stats: %w", err)
+	}
+	stats.Slug = url.Slug
+	stats.From, stats.To, stats.Interval = query.From, query.To, query.Interval
+	stats.Buckets = denseBuckets(stats.Buckets, query)
+	visitors, err := usvc.store.Visitors(ctx, url.Slug)
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("could not get visitors: %w", err)
+	}
+	stats.Visitors = visitors.Estimate()
+	daily, err := usvc.clicks.DailyVisitors(ctx, repository.ClickQuery{
+		Slug: url.Slug,
+		From: visitorsFrom(query),
+		To:   query.To,
+	})
+	if err != nil {
+		return models.ClickStats{}, fmt.Errorf("could not get daily visitors: %w", err)
+	}
+	stats.DailyVisitors = denseVisitors(daily, query)
+	return stats, nil
+}
+
+// statsQuery fills in the defaults of the query, checking that it is valid.
+func (usvc URLService) statsQuery(query StatsQuery) (StatsQuery, error) {
+	if query.Interval == "" {
+		query.Interval = models.IntervalDay
+	}
+	if query.Interval.Duration() == 0 {
+		return StatsQuery{}, fmt.Errorf("interval %q: %w", query.Interval, ErrInvalidStatsQuery)
+	}
+	if query.To.IsZero() {
+		query.To = usvc.now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.Add(-defaultStatsRanges[query.Interval])
+	}
+	query.From, query.To = query.From.UTC(), query.To.UTC()
+	if !query.From.Before(query.To) {
+		return StatsQuery{}, fmt.Errorf("from not before to: %w", ErrInvalidStatsQuery)
+	}
+	buckets := query.Interval.Truncate(query.To.Add(-time.Nanosecond)).Sub(query.Interval.Truncate(query.From))/query.Interval.Duration() + 1
+	if buckets > maxStatsBuckets {
+		return StatsQuery{}, fmt.Errorf("%d buckets, more than %d: %w", buckets, maxStatsBuckets, ErrInvalidStatsQuery)
+	}
+	return query, nil
+}
+
+// denseBuckets returns a bucket per interval of the time range of the query, with the counts of the buckets having clicks.
+func denseBuckets(sparse []models.ClickBucket, query StatsQuery) []models.ClickBucket {
+	counts := make(map[time.Time]int, len(sparse))
+	for _, bucket := range sparse {
+		counts[bucket.Start] = bucket.Count
+	}
+	var buckets []models.ClickBucket
+	for start := query.Interval.Truncate(query.From); start.Before(query.To); start = start.Add(query.Interval.Duration()) {
+		buckets = append(buckets, models.ClickBucket{Start: start, Count: counts[start]})
+	}
+	return buckets
+}
+
+// visitorsFrom returns the start of the first day of the unique visitors per day of the time range of the query,
+// the day of From, unless more than maxStatsBuckets days away from To, e.g. with weekly buckets.
+func visitorsFrom(query StatsQuery) time.Time {
+	from := models.IntervalDay.Truncate(query.From)
+	day := models.IntervalDay.Duration()
+	if earliest := models.IntervalDay.Truncate(query.To.Add(-time.Nanosecond)).Add(-(maxStatsBuckets - 1) * day); from.Before(earliest) {
+		return earliest
+	}
+	return from
+}
+
+// denseVisitors returns the unique visitors per day of the time range of the query, estimated by the daily sketches,
+// for the last maxStatsBuckets days at most.
+func denseVisitors(sketches []models.DailySketch, query StatsQuery) []models.VisitorBucket {
+	estimates := make(map[time.Time]uint64, len(sketches))
+	for _, sketch := range sketches {
+		estimates[sketch.Day] = sketch.Sketch.Estimate()
+	}
+	var buckets []models.VisitorBucket
+	day := models.IntervalDay.Duration()
+	for start := visitorsFrom(query); start.Before(query.To); start = start.Add(day) {
+		buckets = append(buckets, models.VisitorBucket{Day: start, Visitors: estimates[start]})
+	}
+	return buckets
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/indiependente/shrtnr/models"
+	"github.com/indiependente/shrtnr/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLService_Stats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	require.NoError(t, store.Add(ctx, models.URLShortened{Slug: "pizza", URL: "http://indiependente.dev"}))
+	require.NoError(t, store.Add(ctx, models.URLShortened{Slug: "gone", URL: "http://indiependente.dev", ExpiresAt: &anHourAgo, FoldedSlug: true}))
+	clicks := repository.NewMemoryClickStorer()
+	require.NoError(t, clicks.AddClicks(ctx, []models.Click{
+		{Slug: "pizza", Time: now.Add(-3 * time.Hour), Browser: "Firefox"},
+		{Slug: "pizza", Time: now.Add(-3 * time.Hour), Browser: "Chrome"},
+		{Slug: "pizza", Time: now.Add(-time.Hour), Browser: "Firefox"},
+		{Slug: "pizza", Time: now.Add(-48 * time.Hour)},
+		{Slug: "gone", Time: now.Add(-2 * time.Hour)},
+	}))
+	day := now.Truncate(24 * time.Hour)
+	require.NoError(t, store.MergeVisitors(ctx, map[string]models.Sketch{"pizza": {1: 1, 2: 1}}))
+	require.NoError(t, clicks.MergeDailyVisitors(ctx, []models.DailySketch{
+		{Slug: "pizza", Day: day, Sketch: models.Sketch{1: 1, 2: 1}},
+		{Slug: "gone", Day: day, Sketch: models.Sketch{3: 1}},
+	}))
+
+	tests := []struct {
+		name    string
+		slug    string
+		query   StatsQuery
+		want    models.ClickStats
+		wanterr error
+	}{
+		{
+			name:  "Happy Path - hourly",
+			slug:  "pizza",
+			query: StatsQuery{From: now.Add(-4 * time.Hour), Interval: models.IntervalHour},
+			want: models.ClickStats{
+				Slug:     "pizza",
+				From:     now.Add(-4 * time.Hour),
+				To:       now,
+				Interval: models.IntervalHour,
+				Total:    3,
+				Buckets: []models.ClickBucket{
+					{Start: now.Add(-4 * time.Hour), Count: 0},
+					{Start: now.Add(-3 * time.Hour), Count: 2},
+					{Start: now.Add(-2 * time.Hour), Count: 0},
+					{Start: now.Add(-time.Hour), Count: 1},
+				},
+				Referrers:     []models.Tally{},
+				UserAgents:    []models.Tally{},
+				Browsers:      []models.Tally{{Value: "Firefox", Count: 2}, {Value: "Chrome", Count: 1}},
+				Countries:     []models.Tally{},
+				Visitors:      2,
+				DailyVisitors: []models.VisitorBucket{{Day: day, Visitors: 2}},
+			},
+		},
+		{
+			name:  "Happy Path - defaults",
+			slug:  "pizza",
+			query: StatsQuery{},
+			want: models.ClickStats{
+				Slug:     "pizza",
+				From:     now.Add(-30 * 24 * time.Hour),
+				To:       now,
+				Interval: models.IntervalDay,
+				Total:    4,
+				Visitors: 2,
+			},
+		},
+		{
+			name:  "Happy Path - expired",
+			slug:  "gone",
+			query: StatsQuery{From: day, To: day.Add(24 * time.Hour)},
+			want: models.ClickStats{
+				Slug:          "gone",
+				From:          day,
+				To:            day.Add(24 * time.Hour),
+				Interval:      models.IntervalDay,
+				Total:         1,
+				Buckets:       []models.ClickBucket{{Start: day, Count: 1}},
+				Referrers:     []models.Tally{},
+				UserAgents:    []models.Tally{},
+				Browsers:      []models.Tally{},
+				Countries:     []models.Tally{},
+				DailyVisitors: []models.VisitorBucket{{Day: day, Visitors: 1}},
+			},
+		},
+		{
+			name:  "Happy Path - expired, typed in another case",
+			slug:  "GONE",
+			query: StatsQuery{From: day, To: day.Add(24 * time.Hour)},
+			want: models.ClickStats{
+				Slug:          "gone",
+				From:          day,
+				To:            day.Add(24 * time.Hour),
+				Interval:      models.IntervalDay,
+				Total:         1,
+				Buckets:       []models.ClickBucket{{Start: day, Count: 1}},
+				Referrers:     []models.Tally{},
+				UserAgents:    []models.Tally{},
+				Browsers:      []models.Tally{},
+				Countries:     []models.Tally{},
+				DailyVisitors: []models.VisitorBucket{{Day: day, Visitors: 1}},
+			},
+		},
+		{
+			name:    "Sad Path - slug not found",
+			slug:    "pasta",
+			wanterr: ErrSlugNotFound,
+		},
+		{
+			name:    "Sad Path - interval not valid",
+			slug:    "pizza",
+			query:   StatsQuery{Interval: "minute"},
+			wanterr: ErrInvalidStatsQuery,
+		},
+		{
+			name:    "Sad Path - from not before to",
+			slug:    "pizza",
+			query:   StatsQuery{From: now, To: now},
+			wanterr: ErrInvalidStatsQuery,
+		},
+		{
+			name:    "Sad Path - too many buckets",
+			slug:    "pizza",
+			query:   StatsQuery{From: now.Add(-(maxStatsBuckets + 1) * time.Hour), Interval: models.IntervalHour},
+			wanterr: ErrInvalidStatsQuery,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			usvc := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(ctrl), WithClickStore(clicks))
+			usvc.now = func() time.Time { return now }
+
+			got, err := usvc.Stats(ctx, tt.slug, tt.query)
+			if tt.wanterr != nil {
+				require.ErrorIs(t, err, tt.wanterr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want.Buckets == nil { // too many to list, check their span
+				require.Len(t, got.Buckets, 31)
+				require.Equal(t, day.Add(-30*24*time.Hour), got.Buckets[0].Start)
+				require.Len(t, got.DailyVisitors, 31)
+				require.Equal(t, models.VisitorBucket{Day: day, Visitors: 2}, got.DailyVisitors[30])
+				got.Buckets, got.Referrers, got.UserAgents, got.Browsers, got.Countries = nil, nil, nil, nil, nil
+				got.DailyVisitors = nil
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestURLService_StatsDailyVisitorsRange(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	require.NoError(t, store.Add(ctx, models.URLShortened{Slug: "pizza", URL: "http://indiependente.dev"}))
+	usvc := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(gomock.NewController(t)),
+		WithClickStore(repository.NewMemoryClickStorer()))
+	usvc.now = func() time.Time { return now }
+	day := now.Truncate(24 * time.Hour)
+
+	stats, err := usvc.Stats(ctx, "pizza", StatsQuery{
+		From:     now.Add(-(maxStatsBuckets - 1) * 7 * 24 * time.Hour),
+		Interval: models.IntervalWeek,
+	})
+	require.NoError(t, err)
+	require.Len(t, stats.Buckets, maxStatsBuckets)
+	require.Len(t, stats.DailyVisitors, maxStatsBuckets, "daily visitors are capped")
+	require.Equal(t, day.Add(-(maxStatsBuckets-1)*24*time.Hour), stats.DailyVisitors[0].Day)
+	require.Equal(t, day, stats.DailyVisitors[maxStatsBuckets-1].Day)
+}
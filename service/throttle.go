@@ -0,0 +1,86 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxPasswordAttempts is the number of wrong passwords accepted for a slug within passwordAttemptsWindow.
+	maxPasswordAttempts = 5
+	// passwordAttemptsWindow is the time after which the wrong passwords for a slug are forgotten.
+	passwordAttemptsWindow = time.Minute
+	// attemptsSweepSize is the number of tracked slugs above which the forgotten ones are dropped.
+	attemptsSweepSize = 1024
+)
+
+// attemptsThrottler limits the number of failed attempts per slug within a fixed time window.
+// Successful attempts are refunded, so they do not count towards the limit.
+// It is safe for concurrent use.
+type attemptsThrottler struct {
+	max    int
+	window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]attempts
+}
+
+type attempts struct {
+	count int
+	since time.Time
+}
+
+func newAttemptsThrottler(max int, window time.Duration) *attemptsThrottler {
+	return &attemptsThrottler{
+		max:      max,
+		window:   window,
+		attempts: map[string]attempts{},
+	}
+}
+
+// Take records an attempt on the slug at the given time,
+// reporting whether it is allowed or the slug already ran out of attempts in the current window.
+// Attempts are taken before being checked, so that concurrent ones can not exceed the limit.
+func (t *attemptsThrottler) Take(slug string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.attempts[slug]
+	if !ok || now.Sub(a.since) >= t.window {
+		if len(t.attempts) >= attemptsSweepSize {
+			t.sweep(now)
+		}
+		a = attempts{since: now}
+	}
+	if a.count >= t.max {
+		return false
+	}
+	a.count++
+	t.attempts[slug] = a
+	return true
+}
+
+// Refund gives back an attempt taken on the slug that turned out to be successful.
+func (t *attemptsThrottler) Refund(slug string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.attempts[slug]
+	if !ok {
+		return
+	}
+	a.count--
+	if a.count <= 0 {
+		delete(t.attempts, slug)
+		return
+	}
+	t.attempts[slug] = a
+}
+
+// sweep drops the slugs whose window is over.
+// It must be called holding the lock.
+func (t *attemptsThrottler) sweep(now time.Time) {
+	for slug, a := range t.attempts {
+		if now.Sub(a.since) >= t.window {
+			delete(t.attempts, slug)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttemptsThrottler(t *testing.T) {
+	t.Parallel()
+	throttler := newAttemptsThrottler(2, time.Minute)
+
+	require.True(t, throttler.Take("pizza", now))
+	require.True(t, throttler.Take("pizza", now))
+	require.False(t, throttler.Take("pizza", now), "out of attempts")
+	require.True(t, throttler.Take("short", now), "attempts are per slug")
+
+	throttler.Refund("short")
+	require.True(t, throttler.Take("short", now))
+	require.True(t, throttler.Take("short", now), "refunded attempts can be taken again")
+
+	require.True(t, throttler.Take("pizza", now.Add(time.Minute)), "attempts are forgotten after the window")
+}
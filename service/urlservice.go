@@ -5,108 +5,486 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/indiependente/shrtnr/models"
 	"github.com/indiependente/shrtnr/repository"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // URLService implements the Service interface.
 type URLService struct {
 	store   repository.Storer
 	slugger Slugger
+	hits    HitCounter
+	now     func() time.Time
+
+	attempts *attemptsThrottler
+	slugs    *slugStats
+	filter   SlugFilter
+	vanity   VanityPolicy
+	urls     URLNormalizer
+	validate URLValidator
+	blocked  *Blocklist
+	clicks   repository.ClickStorer
+}
+
+// Option configures an optional setting of the URLService.
+type Option func(*URLService)
+
+// WithSlugFilter rejects the slugs, either custom or generated, not allowed by the filter.
+func WithSlugFilter(filter SlugFilter) Option {
+	return func(usvc *URLService) {
+		usvc.filter = filter
+	}
+}
+
+// WithVanityPolicy replaces DefaultVanityPolicy as the policy of the slugs chosen by users.
+func WithVanityPolicy(policy VanityPolicy) Option {
+	return func(usvc *URLService) {
+		usvc.vanity = policy
+	}
+}
+
+// WithURLNormalizer replaces the default URLNormalizer, which neither removes tracking parameters nor fragments.
+func WithURLNormalizer(normalizer URLNormalizer) Option {
+	return func(usvc *URLService) {
+		usvc.urls = normalizer
+	}
+}
+
+// WithURLValidator replaces the default URLValidator, which allows DefaultURLSchemes and knows no own hosts.
+func WithURLValidator(validator URLValidator) Option {
+	return func(usvc *URLService) {
+		usvc.validate = validator
+	}
+}
+
+// WithBlocklist refuses to shorten the urls in the blocklist, and disables the shortened urls redirecting to them.
+func WithBlocklist(blocklist *Blocklist) Option {
+	return func(usvc *URLService) {
+		usvc.blocked = blocklist
+	}
+}
+
+// WithClickStore reads the statistics of the shortened urls from the clicks in the store.
+func WithClickStore(clicks repository.ClickStorer) Option {
+	return func(usvc *URLService) {
+		usvc.clicks = clicks
+	}
 }
 
 // NewURLService returns a new instance of the URLService type.
-func NewURLService(store repository.Storer, slugger Slugger) URLService {
-	return URLService{
+func NewURLService(store repository.Storer, slugger Slugger, hits HitCounter, opts ...Option) URLService {
+	usvc := URLService{
 		store:   store,
 		slugger: slugger,
+		hits:    hits,
+		now:     time.Now,
+
+		attempts: newAttemptsThrottler(maxPasswordAttempts, passwordAttemptsWindow),
+		slugs:    &slugStats{},
+		vanity:   DefaultVanityPolicy,
+		validate: NewURLValidator(DefaultURLSchemes, nil),
 	}
+	for _, opt := range opts {
+		opt(&usvc)
+	}
+	return usvc
 }
 
+// Add adds the shortened url, in its canonical form and if valid, with the slug chosen by the user if any, checked against the vanity policy,
+// or else with a generated one.
+// Returns an error if any.
 func (usvc URLService) Add(ctx context.Context, shortURL models.URLShortened) (models.URLShortened, error) {
+	var err error
+	shortURL.URL, err = usvc.canonicalURL(shortURL.URL)
+	if err != nil {
+		return models.URLShortened{}, fmt.Errorf("could not add: %w", err)
+	}
+	shortURL, err = usvc.settings(shortURL)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
 	if shortURL.Slug == "" {
-		shortURL.Slug = usvc.slugger.Slug()
+		return usvc.addGenerated(ctx, shortURL)
 	}
-	if !usvc.slugger.Validate(shortURL.Slug) {
+	slug, ok := usvc.vanity.Normalize(shortURL.Slug)
+	if !ok {
 		return models.URLShortened{}, fmt.Errorf("could not use slug: %w", ErrInvalidSlug)
 	}
-	err := usvc.store.Add(ctx, shortURL)
+	shortURL.Slug = slug
+	shortURL.FoldedSlug = usvc.vanity.foldCase
+	if !usvc.filter.Allowed(shortURL.Slug) {
+		return models.URLShortened{}, fmt.Errorf("could not use slug: %w", ErrSlugReserved)
+	}
+	err = usvc.store.Add(ctx, shortURL)
 	if err != nil {
 		if errors.Is(err, repository.ErrSlugAlreadyInUse) {
 			return models.URLShortened{}, fmt.Errorf("could not add: %w", ErrSlugAlreadyInUse)
 		}
 		return models.URLShortened{}, fmt.Errorf("could not add: %w", err)
 	}
+	err = usvc.clearClicks(ctx, shortURL.Slug)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	return shortURL, nil
+}
+
+// clearClicks deletes the clicks left by a previous shortened url of the slug, e.g. one purged after expiring,
+// so that the shortened url just added starts without any. If they can not be deleted, neither can the shortened url be added,
+// so it is deleted again.
+// Returns an error if any.
+func (usvc URLService) clearClicks(ctx context.Context, slug string) error {
+	if usvc.clicks == nil {
+		return nil
+	}
+	err := usvc.clicks.DeleteClicks(ctx, slug)
+	if err != nil {
+		_ = usvc.store.Delete(ctx, slug) // best effort, the slug is left in use otherwise
+		return fmt.Errorf("could not clear clicks: %w", err)
+	}
+	return nil
+}
+
+// canonicalURL returns the canonical form of the url, checking that it is valid and not blocked.
+func (usvc URLService) canonicalURL(rawURL string) (string, error) {
+	canonical, err := usvc.urls.Normalize(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrInvalidURL)
+	}
+	err = usvc.validate.Validate(canonical)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrInvalidURL)
+	}
+	if usvc.blocked.Blocked(canonical) {
+		return "", ErrURLBlocked
+	}
+	return canonical, nil
+}
+
+// maxTTL is the longest TTL in seconds, a hundred years, well within the range of a time.Duration.
+const maxTTL = 100 * 365 * 24 * 60 * 60
+
+// maxPasswordLength is the longest password in bytes, the longest bcrypt can hash.
+const maxPasswordLength = 72
+
+// settings validates the per link settings requested on creation,
+// turning the requested TTL into an expiration time and checking that the shortened url is not born expired,
+// and hashing the requested password.
+func (usvc URLService) settings(shortURL models.URLShortened) (models.URLShortened, error) {
+	if shortURL.MaxHits < 0 {
+		return models.URLShortened{}, fmt.Errorf("negative max hits: %w", ErrInvalidMaxHits)
+	}
+	if shortURL.RedirectStatus != 0 && !models.ValidRedirectStatus(shortURL.RedirectStatus) {
+		return models.URLShortened{}, fmt.Errorf("redirect status %d: %w", shortURL.RedirectStatus, ErrInvalidRedirectStatus)
+	}
+	if len(shortURL.Password) > maxPasswordLength {
+		return models.URLShortened{}, fmt.Errorf("password longer than %d bytes: %w", maxPasswordLength, ErrInvalidPassword)
+	}
+	if shortURL.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(shortURL.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return models.URLShortened{}, fmt.Errorf("could not hash password: %w", err)
+		}
+		shortURL.PasswordHash = string(hash)
+		shortURL.Password = ""
+	}
+	switch {
+	case shortURL.TTL < 0:
+		return models.URLShortened{}, fmt.Errorf("negative ttl: %w", ErrInvalidExpiration)
+	case shortURL.TTL > maxTTL:
+		return models.URLShortened{}, fmt.Errorf("ttl longer than %d seconds: %w", maxTTL, ErrInvalidExpiration)
+	case shortURL.TTL > 0 && shortURL.ExpiresAt != nil:
+		return models.URLShortened{}, fmt.Errorf("both ttl and expiration time set: %w", ErrInvalidExpiration)
+	case shortURL.TTL > 0:
+		expiresAt := usvc.now().Add(time.Duration(shortURL.TTL) * time.Second).UTC()
+		shortURL.ExpiresAt = &expiresAt
+		shortURL.TTL = 0
+	case shortURL.Expired(usvc.now()):
+		return models.URLShortened{}, fmt.Errorf("expiration time in the past: %w", ErrInvalidExpiration)
+	}
 	return shortURL, nil
 }
 
+// Get returns the shortened url of the slug.
+// Slugs chosen by users are found whatever their case if the vanity policy folds it.
+// Returns an error if any.
 func (usvc URLService) Get(ctx context.Context, slug string) (models.URLShortened, error) {
+	url, err := usvc.lookup(ctx, slug)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	if url.Expired(usvc.now()) {
+		return models.URLShortened{}, fmt.Errorf("could not get: %w", ErrURLExpired)
+	}
+	return url, nil
+}
+
+// lookup returns the shortened url of the slug as stored, expired or not.
+// Slugs chosen by users are found whatever their case if they were stored folded.
+// Returns an error if any.
+func (usvc URLService) lookup(ctx context.Context, slug string) (models.URLShortened, error) {
 	if slug == "" {
 		return models.URLShortened{}, fmt.Errorf("empty slug: %w", ErrInvalidSlug)
 	}
 	url, err := usvc.store.Get(ctx, slug)
+	if errors.Is(err, repository.ErrSlugNotFound) {
+		url, err = usvc.getFolded(ctx, slug)
+	}
 	if err != nil {
 		if errors.Is(err, repository.ErrSlugNotFound) {
 			return models.URLShortened{}, fmt.Errorf("could not get: %w", ErrSlugNotFound)
 		}
 		return models.URLShortened{}, fmt.Errorf("could not get: %w", err)
 	}
-	// increase hit counter and store the updated value
-	go usvc.increaseHitCounter(url)
 	return url, nil
 }
 
-// increaseHitCounter increases the hit count by one and updates the value in the repo.
-// It is supposed to be called in a separate goroutine.
-func (usvc URLService) increaseHitCounter(url models.URLShortened) {
-	url.Hits++
-	_ = usvc.store.Update(context.Background(), url)
+// getFolded returns the shortened url of the slug typed in another case than the lowercase it was stored folded in.
+// Generated slugs are never folded, since they can be case sensitive: a slug differing only in case is another one.
+// Returns repository.ErrSlugNotFound if there is no such shortened url.
+func (usvc URLService) getFolded(ctx context.Context, slug string) (models.URLShortened, error) {
+	folded := strings.ToLower(slug)
+	if folded == slug {
+		return models.URLShortened{}, repository.ErrSlugNotFound
+	}
+	url, err := usvc.store.Get(ctx, folded)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	if !url.FoldedSlug {
+		return models.URLShortened{}, repository.ErrSlugNotFound
+	}
+	return url, nil
+}
+
+// Resolve returns the shortened url to redirect to, counting the hit.
+// Shortened urls redirecting to blocked urls are never resolved, even if they were shortened before being blocked.
+// Password protected shortened urls are only resolved with the right password,
+// and only a few wrong passwords per slug are checked in a while to slow down brute forcing.
+// Hits on shortened urls having max hits are consumed atomically on the repository,
+// so that concurrent redirects can never exceed the limit.
+// Returns an error if any.
+func (usvc URLService) Resolve(ctx context.Context, slug, password string) (models.URLShortened, error) {
+	url, err := usvc.resolvable(ctx, slug, password)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	if url.MaxHits == 0 {
+		// increase hit counter
+		usvc.hits.Hit(url.Slug)
+		return url, nil
+	}
+	return usvc.consumeHit(ctx, url)
+}
+
+// ResolveBot returns the shortened url to redirect a bot or a crawler to, e.g. a link previewer,
+// counting the hit apart from the ones of people.
+// It checks the shortened url just like Resolve, but never consumes its hits, so that previewing a link can not use it up.
+// Shortened urls having max hits are not resolved for bots then, since anyone can claim to be one
+// to get the original url over and over.
+// Returns an error if any.
+func (usvc URLService) ResolveBot(ctx context.Context, slug, password string) (models.URLShortened, error) {
+	url, err := usvc.resolvable(ctx, slug, password)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	switch {
+	case url.MaxHits > 0 && url.Hits >= url.MaxHits:
+		return models.URLShortened{}, fmt.Errorf("could not resolve: %w", ErrHitLimitReached)
+	case url.MaxHits > 0:
+		return models.URLShortened{}, fmt.Errorf("could not resolve: %w", ErrBotForbidden)
+	}
+	usvc.hits.BotHit(url.Slug)
+	return url, nil
+}
+
+// resolvable returns the shortened url of the slug, checking that it can be redirected to with the password.
+// Returns an error if any.
+func (usvc URLService) resolvable(ctx context.Context, slug, password string) (models.URLShortened, error) {
+	url, err := usvc.Get(ctx, slug)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	if usvc.blocked.Blocked(url.URL) {
+		return models.URLShortened{}, fmt.Errorf("could not resolve: %w", ErrURLBlocked)
+	}
+	if url.Protected() {
+		err = usvc.checkPassword(url, password)
+		if err != nil {
+			return models.URLShortened{}, fmt.Errorf("could not resolve: %w", err)
+		}
+	}
+	return url, nil
+}
+
+// consumeHit consumes a hit of the shortened url having max hits.
+// Returns the updated shortened url or an error if any.
+func (usvc URLService) consumeHit(ctx context.Context, url models.URLShortened) (models.URLShortened, error) {
+	url, err := usvc.store.ConsumeHit(ctx, url.Slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSlugNotFound):
+			return models.URLShortened{}, fmt.Errorf("could not resolve: %w", ErrSlugNotFound)
+		case errors.Is(err, repository.ErrHitLimitReached):
+			return models.URLShortened{}, fmt.Errorf("could not resolve: %w", ErrHitLimitReached)
+		}
+		return models.URLShortened{}, fmt.Errorf("could not resolve: %w", err)
+	}
+	return url, nil
+}
+
+// checkPassword checks the password of a protected shortened url, throttling the attempts on its slug.
+func (usvc URLService) checkPassword(url models.URLShortened, password string) error {
+	if password == "" {
+		return ErrPasswordRequired
+	}
+	if !usvc.attempts.Take(url.Slug, usvc.now()) {
+		return ErrTooManyAttempts
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password))
+	if err != nil {
+		return ErrWrongPassword
+	}
+	usvc.attempts.Refund(url.Slug)
+	return nil
 }
 
 // Shorten returns the shortened URL and shortens it if not found.
+// Urls are looked up and stored in their canonical form, so that the urls pointing to the same resource share the slug.
+// Shortened urls with per link settings are never shared, so a new one is created every time one is requested.
 // Returns an error if any.
-func (usvc URLService) Shorten(ctx context.Context, url string) (models.URLShortened, error) {
-	url = fixURL(url)
-	short, err := usvc.store.GetURL(ctx, url) // try to get from repo
+func (usvc URLService) Shorten(ctx context.Context, shortURL models.URLShortened) (models.URLShortened, error) {
+	var err error
+	shortURL.URL, err = usvc.canonicalURL(shortURL.URL)
 	if err != nil {
-		if !errors.Is(err, repository.ErrURLNotFound) {
+		return models.URLShortened{}, fmt.Errorf("could not shorten: %w", err)
+	}
+	shortURL.Slug = ""
+	if shortURL.Shareable() {
+		short, err := usvc.store.GetShareableURL(ctx, shortURL.URL) // try to get from repo
+		if err != nil && !errors.Is(err, repository.ErrURLNotFound) {
 			return models.URLShortened{}, fmt.Errorf("could not shorten: %w", err)
 		}
-		// create if not found
-		short.URL = url
-		short.Slug = usvc.slugger.Slug()
-		err := usvc.store.Add(ctx, short)
-		if err != nil {
-			if errors.Is(err, repository.ErrSlugAlreadyInUse) {
-				return models.URLShortened{}, fmt.Errorf("could not add: %w", ErrSlugAlreadyInUse)
+		if err == nil {
+			// increase hit counter
+			usvc.hits.Hit(short.Slug)
+			return short, nil
+		}
+	}
+	// create if not found
+	short, err := usvc.settings(shortURL)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	short, err = usvc.addGenerated(ctx, short)
+	if err != nil {
+		return models.URLShortened{}, err
+	}
+	if short.MaxHits == 0 {
+		// increase hit counter, shortening must not consume the hits of a limited one
+		usvc.hits.Hit(short.Slug)
+	}
+	return short, nil
+}
+
+// addGenerated adds the shortened url with a generated slug.
+// Slugs already in use or not allowed by the filter are retried with new ones, up to maxSlugAttempts times,
+// and the ones in use are reported to the slugger if it adapts to collisions.
+// Shareable shortened urls get the slug derived from their url if the slugger is capable of it,
+// so that if the slug is already in use by the same url, that is the shortened url to return.
+// Returns an error if any.
+func (usvc URLService) addGenerated(ctx context.Context, shortURL models.URLShortened) (models.URLShortened, error) {
+	observer, _ := usvc.slugger.(CollisionObserver)
+	urlSlugger, deterministic := usvc.slugger.(URLSlugger)
+	deterministic = deterministic && shortURL.Shareable()
+	for attempt := 1; ; attempt++ {
+		var (
+			slug string
+			err  error
+		)
+		if deterministic {
+			slug = urlSlugger.SlugFor(shortURL.URL, attempt-1)
+		} else {
+			slug, err = usvc.slugger.Slug(ctx)
+			if err != nil {
+				return models.URLShortened{}, fmt.Errorf("could not generate slug: %w", err)
+			}
+		}
+		shortURL.Slug = slug
+		if !usvc.slugger.Validate(shortURL.Slug) {
+			return models.URLShortened{}, fmt.Errorf("could not use slug: %w", ErrInvalidSlug)
+		}
+		if !usvc.filter.Allowed(shortURL.Slug) {
+			// just as unusable as a slug in use, without saying anything about the keyspace
+			if attempt == maxSlugAttempts {
+				usvc.slugs.exhausted(attempt)
+				return models.URLShortened{}, fmt.Errorf("could not add after %d attempts: %w", attempt, ErrSlugReserved)
 			}
+			continue
+		}
+		err = usvc.store.Add(ctx, shortURL)
+		collided := errors.Is(err, repository.ErrSlugAlreadyInUse)
+		if observer != nil && (err == nil || collided) {
+			observer.Observe(collided)
+		}
+		if collided && deterministic {
+			existing, getErr := usvc.store.Get(ctx, slug)
+			if getErr == nil && existing.URL == shortURL.URL && existing.Shareable() {
+				usvc.slugs.generated(attempt)
+				return existing, nil
+			}
+		}
+		switch {
+		case err == nil:
+			usvc.slugs.generated(attempt)
+			if err := usvc.clearClicks(ctx, shortURL.Slug); err != nil {
+				return models.URLShortened{}, err
+			}
+			return shortURL, nil
+		case !collided:
 			return models.URLShortened{}, fmt.Errorf("could not add: %w", err)
+		case attempt == maxSlugAttempts:
+			usvc.slugs.exhausted(attempt)
+			return models.URLShortened{}, fmt.Errorf("could not add after %d attempts: %w", attempt, ErrSlugAlreadyInUse)
 		}
 	}
-	// increase hit counter and store the updated value
-	go usvc.increaseHitCounter(short)
-	return short, nil
 }
 
-// Delete deletes the entry related to the input slug from the repository.
+// SlugStats returns the statistics of the generated slugs.
+func (usvc URLService) SlugStats() SlugStats {
+	return usvc.slugs.stats()
+}
+
+// Delete deletes the entry related to the input slug from the repository, along with its clicks if stored.
+// Slugs chosen by users are found whatever their case if they were stored folded, as in Get.
+// Returns an error if any.
 func (usvc URLService) Delete(ctx context.Context, slug string) error {
 	if slug == "" {
 		return fmt.Errorf("empty slug: %w", ErrInvalidSlug)
 	}
 	err := usvc.store.Delete(ctx, slug)
+	if errors.Is(err, repository.ErrSlugNotFound) {
+		var url models.URLShortened
+		url, err = usvc.getFolded(ctx, slug)
+		if err == nil {
+			slug = url.Slug
+			err = usvc.store.Delete(ctx, slug)
+		}
+	}
 	if err != nil {
 		if errors.Is(err, repository.ErrSlugNotFound) {
 			return fmt.Errorf("could not delete: %w", ErrSlugNotFound)
 		}
 		return fmt.Errorf("could not delete: %w", err)
 	}
-	return nil
-}
-
-func fixURL(url string) string {
-	if !strings.HasPrefix(url, "http") {
-		url = "http://" + url
+	if usvc.clicks != nil {
+		err = usvc.clicks.DeleteClicks(ctx, slug)
+		if err != nil {
+			return fmt.Errorf("could not delete clicks: %w", err)
+		}
 	}
-	return url
+	return nil
 }
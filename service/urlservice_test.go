@@ -3,14 +3,34 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/indiependente/pkg/logger"
 	"github.com/indiependente/shrtnr/models"
 	"github.com/indiependente/shrtnr/repository"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	now       = time.Date(2020, time.October, 18, 12, 0, 0, 0, time.UTC)
+	inAnHour  = now.Add(time.Hour)
+	anHourAgo = now.Add(-time.Hour)
+)
+
+// pizzaHash is the bcrypt hash of the "pizza" password.
+const pizzaHash = "$2a$04$VnZL2/aZkIogeRuueR39ouFBuNRkgwuDnveqL3SLrV0ArMvaRexOW"
+
 func TestURLService_Add(t *testing.T) {
 	t.Parallel()
 
@@ -24,7 +44,7 @@ func TestURLService_Add(t *testing.T) {
 		{
 			name: "Happy Path",
 			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
-				slugger.EXPECT().Slug().Return("pizza")
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
 				slugger.EXPECT().Validate("pizza").Return(true)
 				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(nil)
 			},
@@ -38,10 +58,32 @@ func TestURLService_Add(t *testing.T) {
 			},
 			wanterr: false,
 		},
+		{
+			name: "Happy Path - ttl",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
+				slugger.EXPECT().Validate("pizza").Return(true)
+				store.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:       "http://indiependente.dev",
+					Slug:      "pizza",
+					ExpiresAt: &inAnHour,
+				}).Return(nil)
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+				TTL: 3600,
+			},
+			wanturl: models.URLShortened{
+				URL:       "http://indiependente.dev",
+				Slug:      "pizza",
+				ExpiresAt: &inAnHour,
+			},
+			wanterr: false,
+		},
 		{
 			name: "Sad Path - zero length slug",
 			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
-				slugger.EXPECT().Slug().Return("")
+				slugger.EXPECT().Slug(gomock.Any()).Return("", nil)
 				slugger.EXPECT().Validate("").Return(false)
 			},
 			url: models.URLShortened{
@@ -49,12 +91,100 @@ func TestURLService_Add(t *testing.T) {
 			},
 			wanterr: true,
 		},
+		{
+			name:              "Sad Path - private address",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:  "http://192.168.1.1/admin",
+				Slug: "router",
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - negative ttl",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+				TTL: -1,
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - ttl overflowing the expiration time",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+				TTL: math.MaxInt64,
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - both ttl and expiration time",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:       "http://indiependente.dev",
+				TTL:       3600,
+				ExpiresAt: &inAnHour,
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - redirect status not valid",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:            "http://indiependente.dev",
+				RedirectStatus: http.StatusOK,
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - negative max hits",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:     "http://indiependente.dev",
+				MaxHits: -1,
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - expiration time in the past",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:       "http://indiependente.dev",
+				ExpiresAt: &anHourAgo,
+			},
+			wanterr: true,
+		},
+		{
+			name: "Happy Path - generated slug in use is retried",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				gomock.InOrder(
+					slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil),
+					slugger.EXPECT().Validate("pizza").Return(true),
+					store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(repository.ErrSlugAlreadyInUse),
+					slugger.EXPECT().Slug(gomock.Any()).Return("pasta", nil),
+					slugger.EXPECT().Validate("pasta").Return(true),
+					store.EXPECT().Add(gomock.Any(), models.URLShortened{
+						URL:  "http://indiependente.dev",
+						Slug: "pasta",
+					}).Return(nil),
+				)
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanturl: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pasta",
+			},
+			wanterr: false,
+		},
 		{
 			name: "Sad Path - slug in use",
 			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
-				slugger.EXPECT().Slug().Return("pizza")
-				slugger.EXPECT().Validate("pizza").Return(true)
-				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(repository.ErrSlugAlreadyInUse)
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil).Times(maxSlugAttempts)
+				slugger.EXPECT().Validate("pizza").Return(true).Times(maxSlugAttempts)
+				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(repository.ErrSlugAlreadyInUse).Times(maxSlugAttempts)
 			},
 			url: models.URLShortened{
 				URL: "http://indiependente.dev",
@@ -64,7 +194,7 @@ func TestURLService_Add(t *testing.T) {
 		{
 			name: "Sad Path - unexpected error",
 			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
-				slugger.EXPECT().Slug().Return("pizza")
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
 				slugger.EXPECT().Validate("pizza").Return(true)
 				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(errors.New("unexpected error"))
 			},
@@ -73,6 +203,111 @@ func TestURLService_Add(t *testing.T) {
 			},
 			wanterr: true,
 		},
+		{
+			name: "Happy Path - custom slug",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			url: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pizza",
+			},
+			wanturl: models.URLShortened{
+				URL:        "http://indiependente.dev",
+				Slug:       "pizza",
+				FoldedSlug: true,
+			},
+			wanterr: false,
+		},
+		{
+			name: "Happy Path - custom slug folded to lowercase",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				store.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:        "http://indiependente.dev",
+					Slug:       "team-offsite_2026",
+					FoldedSlug: true,
+				}).Return(nil)
+			},
+			url: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "Team-Offsite_2026",
+			},
+			wanturl: models.URLShortened{
+				URL:        "http://indiependente.dev",
+				Slug:       "team-offsite_2026",
+				FoldedSlug: true,
+			},
+			wanterr: false,
+		},
+		{
+			name:              "Sad Path - custom slug not valid",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "team offsite",
+			},
+			wanterr: true,
+		},
+		{
+			name: "Sad Path - custom slug in use",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(repository.ErrSlugAlreadyInUse)
+			},
+			url: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pizza",
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - reserved custom slug",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "Admin",
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - blocked custom slug",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			url: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "darnit",
+			},
+			wanterr: true,
+		},
+		{
+			name: "Happy Path - blocked generated slug is retried",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				gomock.InOrder(
+					slugger.EXPECT().Slug(gomock.Any()).Return("adarn", nil),
+					slugger.EXPECT().Validate("adarn").Return(true),
+					slugger.EXPECT().Slug(gomock.Any()).Return("pasta", nil),
+					slugger.EXPECT().Validate("pasta").Return(true),
+					store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(nil),
+				)
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanturl: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pasta",
+			},
+			wanterr: false,
+		},
+		{
+			name: "Sad Path - generated slugs always blocked",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				slugger.EXPECT().Slug(gomock.Any()).Return("adarn", nil).Times(maxSlugAttempts)
+				slugger.EXPECT().Validate("adarn").Return(true).Times(maxSlugAttempts)
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanterr: true,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -81,9 +316,13 @@ func TestURLService_Add(t *testing.T) {
 			defer ctrl.Finish()
 			mockStore := repository.NewMockStorer(ctrl)
 			mockSlugger := NewMockSlugger(ctrl)
+			mockHits := NewMockHitCounter(ctrl)
 			tt.setupExpectations(mockStore, mockSlugger)
 
-			usvc := NewURLService(mockStore, mockSlugger)
+			usvc := NewURLService(mockStore, mockSlugger, mockHits,
+				WithSlugFilter(NewSlugFilter([]string{"admin"}, []string{"darn"})),
+			)
+			usvc.now = func() time.Time { return now }
 
 			ctx := context.Background()
 			url, err := usvc.Add(ctx, tt.url)
@@ -110,6 +349,28 @@ func TestURLService_Delete(t *testing.T) {
 			},
 			wanterr: false,
 		},
+		{
+			name: "Happy Path - vanity slug typed in another case",
+			slug: "MyPizza",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				gomock.InOrder(
+					store.EXPECT().Delete(gomock.Any(), "MyPizza").Return(repository.ErrSlugNotFound),
+					store.EXPECT().Get(gomock.Any(), "mypizza").Return(models.URLShortened{Slug: "mypizza", FoldedSlug: true}, nil),
+					store.EXPECT().Delete(gomock.Any(), "mypizza").Return(nil),
+				)
+			},
+			wanterr: false,
+		},
+		{
+			name: "Sad Path - generated slug differing only in case",
+			slug: "Ab3x",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				store.EXPECT().Delete(gomock.Any(), "Ab3x").Return(repository.ErrSlugNotFound)
+				// a case sensitive generated slug, which is another one
+				store.EXPECT().Get(gomock.Any(), "ab3x").Return(models.URLShortened{Slug: "ab3x"}, nil)
+			},
+			wanterr: true,
+		},
 		{
 			name:              "Sad Path - zero length slug",
 			slug:              "",
@@ -124,6 +385,15 @@ func TestURLService_Delete(t *testing.T) {
 			},
 			wanterr: true,
 		},
+		{
+			name: "Sad Path - slug not found in any case",
+			slug: "MyPizza",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+				store.EXPECT().Delete(gomock.Any(), "MyPizza").Return(repository.ErrSlugNotFound)
+				store.EXPECT().Get(gomock.Any(), "mypizza").Return(models.URLShortened{}, repository.ErrSlugNotFound)
+			},
+			wanterr: true,
+		},
 		{
 			name: "Sad Path - unexpected error",
 			slug: "short",
@@ -140,9 +410,10 @@ func TestURLService_Delete(t *testing.T) {
 			defer ctrl.Finish()
 			mockStore := repository.NewMockStorer(ctrl)
 			mockSlugger := NewMockSlugger(ctrl)
+			mockHits := NewMockHitCounter(ctrl)
 			tt.setupExpectations(mockStore, mockSlugger)
 
-			usvc := NewURLService(mockStore, mockSlugger)
+			usvc := NewURLService(mockStore, mockSlugger, mockHits)
 
 			ctx := context.Background()
 			err := usvc.Delete(ctx, tt.slug)
@@ -151,30 +422,72 @@ func TestURLService_Delete(t *testing.T) {
 	}
 }
 
+func TestURLService_DeleteClicks(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	clicks := repository.NewMemoryClickStorer()
+	usvc := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(gomock.NewController(t)), WithClickStore(clicks))
+	day := now.Truncate(24 * time.Hour)
+	record := func(slug string) {
+		require.NoError(t, clicks.AddClicks(ctx, []models.Click{{Slug: slug, Time: now}}))
+		require.NoError(t, clicks.MergeDailyVisitors(ctx, []models.DailySketch{{Slug: slug, Day: day, Sketch: models.Sketch{1: 1}}}))
+	}
+	count := func(slug string) int {
+		var n int
+		err := clicks.IterateClicks(ctx, repository.ClickQuery{Slug: slug}, func(models.Click) error {
+			n++
+			return nil
+		})
+		require.NoError(t, err)
+		visitors, err := clicks.DailyVisitors(ctx, repository.ClickQuery{Slug: slug})
+		require.NoError(t, err)
+		return n + len(visitors)
+	}
+
+	// clicks left by a shortened url purged after expiring are not inherited
+	record("pizza")
+	_, err := usvc.Add(ctx, models.URLShortened{Slug: "pizza", URL: "http://indiependente.dev"})
+	require.NoError(t, err)
+	require.Zero(t, count("pizza"))
+
+	record("pizza")
+	record("pasta")
+	require.NoError(t, usvc.Delete(ctx, "PIZZA"))
+	require.Zero(t, count("pizza"), "clicks are deleted along with the shortened url")
+	require.Equal(t, 2, count("pasta"))
+
+	// a shortened url whose leftover clicks can not be deleted is not added
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockClicks := repository.NewMockClickStorer(ctrl)
+	mockClicks.EXPECT().DeleteClicks(gomock.Any(), "pasta").Return(errors.New("unexpected error"))
+	failing := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(ctrl), WithClickStore(mockClicks))
+	_, err = failing.Add(ctx, models.URLShortened{Slug: "pasta", URL: "http://indiependente.dev"})
+	require.Error(t, err)
+	_, err = store.Get(ctx, "pasta")
+	require.True(t, errors.Is(err, repository.ErrSlugNotFound), "got error %v", err)
+}
+
 func TestURLService_Get(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
 		name              string
 		slug              string
-		setupExpectations func(storer *repository.MockStorer, slugger *MockSlugger)
+		setupExpectations func(storer *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter)
 		url               models.URLShortened
 		wanterr           bool
 	}{
 		{
 			name: "Happy Path",
 			slug: "short",
-			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
 				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
 					Slug: "short",
 					URL:  "http://indiependente.dev",
 					Hits: 1,
 				}, nil)
-				store.EXPECT().Update(gomock.Any(), models.URLShortened{
-					Slug: "short",
-					URL:  "http://indiependente.dev",
-					Hits: 2,
-				}).MaxTimes(1).Return(nil)
 			},
 			url: models.URLShortened{
 				Slug: "short",
@@ -186,14 +499,71 @@ func TestURLService_Get(t *testing.T) {
 		{
 			name:              "Sad Path - zero length slug",
 			slug:              "",
-			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {},
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {},
 			url:               models.URLShortened{},
 			wanterr:           true,
 		},
+		{
+			name: "Sad Path - expired",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:      "short",
+					URL:       "http://indiependente.dev",
+					ExpiresAt: &anHourAgo,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: true,
+		},
+		{
+			name: "Happy Path - custom slug in another case",
+			slug: "Team-Offsite",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				gomock.InOrder(
+					store.EXPECT().Get(gomock.Any(), "Team-Offsite").Return(models.URLShortened{}, repository.ErrSlugNotFound),
+					store.EXPECT().Get(gomock.Any(), "team-offsite").Return(models.URLShortened{
+						Slug:       "team-offsite",
+						URL:        "http://indiependente.dev",
+						FoldedSlug: true,
+					}, nil),
+				)
+			},
+			url: models.URLShortened{
+				Slug:       "team-offsite",
+				URL:        "http://indiependente.dev",
+				FoldedSlug: true,
+			},
+			wanterr: false,
+		},
+		{
+			name: "Sad Path - generated slug differing only in case",
+			slug: "Ab3x",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "Ab3x").Return(models.URLShortened{}, repository.ErrSlugNotFound)
+				// a case sensitive generated slug, which is another one
+				store.EXPECT().Get(gomock.Any(), "ab3x").Return(models.URLShortened{
+					Slug: "ab3x",
+					URL:  "http://indiependente.dev",
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: true,
+		},
 		{
 			name: "Sad Path - slug not found",
 			slug: "short",
-			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{}, repository.ErrSlugNotFound)
+			},
+			url:     models.URLShortened{},
+			wanterr: true,
+		},
+		{
+			name: "Sad Path - slug not found in any case",
+			slug: "Short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "Short").Return(models.URLShortened{}, repository.ErrSlugNotFound)
 				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{}, repository.ErrSlugNotFound)
 			},
 			url:     models.URLShortened{},
@@ -202,7 +572,7 @@ func TestURLService_Get(t *testing.T) {
 		{
 			name: "Sad Path - unexpected error",
 			slug: "short",
-			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger) {
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
 				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{}, errors.New("unexpected error"))
 			},
 			url:     models.URLShortened{},
@@ -216,9 +586,11 @@ func TestURLService_Get(t *testing.T) {
 			defer ctrl.Finish()
 			mockStore := repository.NewMockStorer(ctrl)
 			mockSlugger := NewMockSlugger(ctrl)
-			tt.setupExpectations(mockStore, mockSlugger)
+			mockHits := NewMockHitCounter(ctrl)
+			tt.setupExpectations(mockStore, mockSlugger, mockHits)
 
-			usvc := NewURLService(mockStore, mockSlugger)
+			usvc := NewURLService(mockStore, mockSlugger, mockHits)
+			usvc.now = func() time.Time { return now }
 
 			ctx := context.Background()
 			url, err := usvc.Get(ctx, tt.slug)
@@ -227,3 +599,682 @@ func TestURLService_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestURLService_Resolve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		slug              string
+		password          string
+		setupExpectations func(storer *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter)
+		url               models.URLShortened
+		wanterr           error
+	}{
+		{
+			name: "Happy Path",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug: "short",
+					URL:  "http://indiependente.dev",
+					Hits: 1,
+				}, nil)
+				hits.EXPECT().Hit("short")
+			},
+			url: models.URLShortened{
+				Slug: "short",
+				URL:  "http://indiependente.dev",
+				Hits: 1,
+			},
+			wanterr: nil,
+		},
+		{
+			name: "Happy Path - max hits consumes a hit",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:    "short",
+					URL:     "http://indiependente.dev",
+					Hits:    1,
+					MaxHits: 3,
+				}, nil)
+				store.EXPECT().ConsumeHit(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:    "short",
+					URL:     "http://indiependente.dev",
+					Hits:    2,
+					MaxHits: 3,
+				}, nil)
+			},
+			url: models.URLShortened{
+				Slug:    "short",
+				URL:     "http://indiependente.dev",
+				Hits:    2,
+				MaxHits: 3,
+			},
+			wanterr: nil,
+		},
+		{
+			name: "Sad Path - hit limit reached",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:    "short",
+					URL:     "http://indiependente.dev",
+					Hits:    2,
+					MaxHits: 3,
+				}, nil)
+				store.EXPECT().ConsumeHit(gomock.Any(), "short").Return(models.URLShortened{}, repository.ErrHitLimitReached)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrHitLimitReached,
+		},
+		{
+			name: "Sad Path - deleted while resolving",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:    "short",
+					URL:     "http://indiependente.dev",
+					MaxHits: 3,
+				}, nil)
+				store.EXPECT().ConsumeHit(gomock.Any(), "short").Return(models.URLShortened{}, repository.ErrSlugNotFound)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrSlugNotFound,
+		},
+		{
+			name:     "Happy Path - right password",
+			slug:     "short",
+			password: "pizza",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:         "short",
+					URL:          "http://indiependente.dev",
+					PasswordHash: pizzaHash,
+				}, nil)
+				hits.EXPECT().Hit("short")
+			},
+			url: models.URLShortened{
+				Slug:         "short",
+				URL:          "http://indiependente.dev",
+				PasswordHash: pizzaHash,
+			},
+			wanterr: nil,
+		},
+		{
+			name: "Sad Path - password required",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:         "short",
+					URL:          "http://indiependente.dev",
+					PasswordHash: pizzaHash,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrPasswordRequired,
+		},
+		{
+			name:     "Sad Path - wrong password",
+			slug:     "short",
+			password: "pasta",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:         "short",
+					URL:          "http://indiependente.dev",
+					PasswordHash: pizzaHash,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrWrongPassword,
+		},
+		{
+			name: "Sad Path - expired",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:      "short",
+					URL:       "http://indiependente.dev",
+					ExpiresAt: &anHourAgo,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrURLExpired,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStore := repository.NewMockStorer(ctrl)
+			mockSlugger := NewMockSlugger(ctrl)
+			mockHits := NewMockHitCounter(ctrl)
+			tt.setupExpectations(mockStore, mockSlugger, mockHits)
+
+			usvc := NewURLService(mockStore, mockSlugger, mockHits)
+			usvc.now = func() time.Time { return now }
+
+			ctx := context.Background()
+			url, err := usvc.Resolve(ctx, tt.slug, tt.password)
+			if tt.wanterr != nil {
+				require.ErrorIs(t, err, tt.wanterr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.url, url)
+		})
+	}
+}
+
+func TestURLService_ResolveBot(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		slug              string
+		password          string
+		setupExpectations func(storer *repository.MockStorer, hits *MockHitCounter)
+		url               models.URLShortened
+		wanterr           error
+	}{
+		{
+			name: "Happy Path",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug: "short",
+					URL:  "http://indiependente.dev",
+					Hits: 1,
+				}, nil)
+				hits.EXPECT().BotHit("short")
+			},
+			url: models.URLShortened{
+				Slug: "short",
+				URL:  "http://indiependente.dev",
+				Hits: 1,
+			},
+			wanterr: nil,
+		},
+		{
+			name: "Sad Path - max hits are neither consumed nor bypassed",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:    "short",
+					URL:     "http://indiependente.dev",
+					Hits:    2,
+					MaxHits: 3,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrBotForbidden,
+		},
+		{
+			name: "Sad Path - hit limit reached",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:    "short",
+					URL:     "http://indiependente.dev",
+					Hits:    3,
+					MaxHits: 3,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrHitLimitReached,
+		},
+		{
+			name: "Sad Path - password required",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{
+					Slug:         "short",
+					URL:          "http://indiependente.dev",
+					PasswordHash: pizzaHash,
+				}, nil)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrPasswordRequired,
+		},
+		{
+			name: "Sad Path - slug not found",
+			slug: "short",
+			setupExpectations: func(store *repository.MockStorer, hits *MockHitCounter) {
+				store.EXPECT().Get(gomock.Any(), "short").Return(models.URLShortened{}, repository.ErrSlugNotFound)
+			},
+			url:     models.URLShortened{},
+			wanterr: ErrSlugNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStore := repository.NewMockStorer(ctrl)
+			mockHits := NewMockHitCounter(ctrl)
+			tt.setupExpectations(mockStore, mockHits)
+
+			usvc := NewURLService(mockStore, NewMockSlugger(ctrl), mockHits)
+			usvc.now = func() time.Time { return now }
+
+			url, err := usvc.ResolveBot(context.Background(), tt.slug, tt.password)
+			if tt.wanterr != nil {
+				require.ErrorIs(t, err, tt.wanterr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.url, url)
+		})
+	}
+}
+
+func TestURLService_ResolveThrottlesPasswords(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	err := store.Add(ctx, models.URLShortened{
+		Slug:         "short",
+		URL:          "http://indiependente.dev",
+		PasswordHash: pizzaHash,
+	})
+	require.NoError(t, err)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockHits := NewMockHitCounter(ctrl)
+	mockHits.EXPECT().Hit("short")
+	usvc := NewURLService(store, NewFixedLenSlugger(5), mockHits)
+	clock := now
+	usvc.now = func() time.Time { return clock }
+
+	for i := 0; i < maxPasswordAttempts; i++ {
+		_, err = usvc.Resolve(ctx, "short", "pasta")
+		require.ErrorIs(t, err, ErrWrongPassword)
+	}
+	// even the right password is not checked until the window is over
+	_, err = usvc.Resolve(ctx, "short", "pizza")
+	require.ErrorIs(t, err, ErrTooManyAttempts)
+
+	clock = clock.Add(passwordAttemptsWindow)
+	url, err := usvc.Resolve(ctx, "short", "pizza")
+	require.NoError(t, err)
+	require.Equal(t, "http://indiependente.dev", url.URL)
+}
+
+func TestURLService_AddPassword(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	usvc := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(gomock.NewController(t)))
+
+	short, err := usvc.Add(ctx, models.URLShortened{
+		Slug:     "short",
+		URL:      "http://indiependente.dev",
+		Password: "pizza",
+	})
+	require.NoError(t, err)
+	require.Empty(t, short.Password)
+	stored, err := store.Get(ctx, "short")
+	require.NoError(t, err)
+	require.Empty(t, stored.Password, "the password must never be stored in clear")
+	require.True(t, stored.Protected())
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(stored.PasswordHash), []byte("pizza")))
+}
+
+func TestURLService_AddPasswordTooLong(t *testing.T) {
+	t.Parallel()
+	store := repository.NewMemoryURLStorer()
+	usvc := NewURLService(store, NewFixedLenSlugger(5), NewMockHitCounter(gomock.NewController(t)))
+
+	_, err := usvc.Add(context.Background(), models.URLShortened{
+		Slug:     "short",
+		URL:      "http://indiependente.dev",
+		Password: strings.Repeat("p", maxPasswordLength+1),
+	})
+	require.True(t, errors.Is(err, ErrInvalidPassword), "got error %v", err)
+	_, err = store.Get(context.Background(), "short")
+	require.True(t, errors.Is(err, repository.ErrSlugNotFound), "got error %v", err)
+}
+
+func TestURLService_Shorten(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		setupExpectations func(storer *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter)
+		url               models.URLShortened
+		wanturl           models.URLShortened
+		wanterr           bool
+	}{
+		{
+			name: "Happy Path - already shortened",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().GetShareableURL(gomock.Any(), "http://indiependente.dev").Return(models.URLShortened{
+					URL:  "http://indiependente.dev",
+					Slug: "pizza",
+					Hits: 1,
+				}, nil)
+				hits.EXPECT().Hit("pizza")
+			},
+			url: models.URLShortened{
+				URL: "indiependente.dev",
+			},
+			wanturl: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pizza",
+				Hits: 1,
+			},
+			wanterr: false,
+		},
+		{
+			name: "Happy Path - already shortened in another form",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().GetShareableURL(gomock.Any(), "https://indiependente.dev/blog?a=1&b=2").Return(models.URLShortened{
+					URL:  "https://indiependente.dev/blog?a=1&b=2",
+					Slug: "pizza",
+					Hits: 1,
+				}, nil)
+				hits.EXPECT().Hit("pizza")
+			},
+			url: models.URLShortened{
+				URL: "HTTPS://Indiependente.DEV:443/blog?b=2&a=1",
+			},
+			wanturl: models.URLShortened{
+				URL:  "https://indiependente.dev/blog?a=1&b=2",
+				Slug: "pizza",
+				Hits: 1,
+			},
+			wanterr: false,
+		},
+		{
+			name:              "Sad Path - url not allowed",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {},
+			url: models.URLShortened{
+				URL: "javascript:alert(1)",
+			},
+			wanterr: true,
+		},
+		{
+			name:              "Sad Path - url not parsable",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev/%zz",
+			},
+			wanterr: true,
+		},
+		{
+			name: "Happy Path - not shortened yet",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().GetShareableURL(gomock.Any(), "http://indiependente.dev").Return(models.URLShortened{}, repository.ErrURLNotFound)
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
+				slugger.EXPECT().Validate("pizza").Return(true)
+				store.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:  "http://indiependente.dev",
+					Slug: "pizza",
+				}).Return(nil)
+				hits.EXPECT().Hit("pizza")
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanturl: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pizza",
+			},
+			wanterr: false,
+		},
+		{
+			name: "Happy Path - shortened with expiration is not shared",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				// the repository skips the shortened urls with per link settings
+				store.EXPECT().GetShareableURL(gomock.Any(), "http://indiependente.dev").Return(models.URLShortened{}, repository.ErrURLNotFound)
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
+				slugger.EXPECT().Validate("pizza").Return(true)
+				store.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:  "http://indiependente.dev",
+					Slug: "pizza",
+				}).Return(nil)
+				hits.EXPECT().Hit("pizza")
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanturl: models.URLShortened{
+				URL:  "http://indiependente.dev",
+				Slug: "pizza",
+			},
+			wanterr: false,
+		},
+		{
+			name: "Happy Path - ttl always creates a new one",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
+				slugger.EXPECT().Validate("pizza").Return(true)
+				store.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:       "http://indiependente.dev",
+					Slug:      "pizza",
+					ExpiresAt: &inAnHour,
+				}).Return(nil)
+				hits.EXPECT().Hit("pizza")
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+				TTL: 3600,
+			},
+			wanturl: models.URLShortened{
+				URL:       "http://indiependente.dev",
+				Slug:      "pizza",
+				ExpiresAt: &inAnHour,
+			},
+			wanterr: false,
+		},
+		{
+			name: "Happy Path - max hits always creates a new one without counting a hit",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil)
+				slugger.EXPECT().Validate("pizza").Return(true)
+				store.EXPECT().Add(gomock.Any(), models.URLShortened{
+					URL:     "http://indiependente.dev",
+					Slug:    "pizza",
+					MaxHits: 1,
+				}).Return(nil)
+			},
+			url: models.URLShortened{
+				URL:     "http://indiependente.dev",
+				MaxHits: 1,
+			},
+			wanturl: models.URLShortened{
+				URL:     "http://indiependente.dev",
+				Slug:    "pizza",
+				MaxHits: 1,
+			},
+			wanterr: false,
+		},
+		{
+			name:              "Sad Path - expiration time in the past",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {},
+			url: models.URLShortened{
+				URL:       "http://indiependente.dev",
+				ExpiresAt: &anHourAgo,
+			},
+			wanterr: true,
+		},
+		{
+			name: "Sad Path - slug in use",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().GetShareableURL(gomock.Any(), "http://indiependente.dev").Return(models.URLShortened{}, repository.ErrURLNotFound)
+				slugger.EXPECT().Slug(gomock.Any()).Return("pizza", nil).Times(maxSlugAttempts)
+				slugger.EXPECT().Validate("pizza").Return(true).Times(maxSlugAttempts)
+				store.EXPECT().Add(gomock.Any(), gomock.Any()).Return(repository.ErrSlugAlreadyInUse).Times(maxSlugAttempts)
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanterr: true,
+		},
+		{
+			name: "Sad Path - unexpected error",
+			setupExpectations: func(store *repository.MockStorer, slugger *MockSlugger, hits *MockHitCounter) {
+				store.EXPECT().GetShareableURL(gomock.Any(), "http://indiependente.dev").Return(models.URLShortened{}, errors.New("unexpected error"))
+			},
+			url: models.URLShortened{
+				URL: "http://indiependente.dev",
+			},
+			wanterr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockStore := repository.NewMockStorer(ctrl)
+			mockSlugger := NewMockSlugger(ctrl)
+			mockHits := NewMockHitCounter(ctrl)
+			tt.setupExpectations(mockStore, mockSlugger, mockHits)
+
+			usvc := NewURLService(mockStore, mockSlugger, mockHits)
+			usvc.now = func() time.Time { return now }
+
+			ctx := context.Background()
+			url, err := usvc.Shorten(ctx, tt.url)
+			require.Equal(t, tt.wanterr, err != nil)
+			require.Equal(t, tt.wanturl, url)
+		})
+	}
+}
+
+func TestURLService_ResolveConcurrentHits(t *testing.T) {
+	t.Parallel()
+	const resolves = 5000
+
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	err := store.Add(ctx, models.URLShortened{
+		Slug: "short",
+		URL:  "http://indiependente.dev",
+	})
+	require.NoError(t, err)
+	hits := NewHitAggregator(store, time.Millisecond, 1)
+	go hits.Start(ctx)
+	usvc := NewURLService(store, NewFixedLenSlugger(5), hits)
+
+	var wg sync.WaitGroup
+	for i := 0; i < resolves; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := usvc.Resolve(ctx, "short", "")
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	err = hits.Shutdown(ctx)
+	require.NoError(t, err)
+	url, err := store.Get(ctx, "short")
+	require.NoError(t, err)
+	require.Equal(t, resolves, url.Hits)
+}
+
+func TestURLService_ShortenGrowsSlugs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := repository.NewMemoryURLStorer()
+	// a single letter keyspace saturates immediately
+	slugger, err := NewGrowingSlugger("ab", 1, 10)
+	require.NoError(t, err)
+	hits := NewHitAggregator(store, time.Hour, 0)
+	usvc := NewURLService(store, slugger, hits)
+
+	const shortens = 50
+	for i := 0; i < shortens; i++ {
+		_, err := usvc.Shorten(ctx, models.URLShortened{URL: fmt.Sprintf("http://indiependente.dev/%d", i)})
+		if err != nil {
+			// a few shortens may run out of attempts before the slugs grow
+			require.ErrorIs(t, err, ErrSlugAlreadyInUse)
+		}
+	}
+	stats := usvc.SlugStats()
+	require.Equal(t, uint64(shortens), stats.Generated+stats.Exhausted)
+	require.Less(t, stats.Exhausted, uint64(shortens/2))
+	require.NotZero(t, stats.Retries)
+	require.Greater(t, slugger.Len(), 3, "50 slugs do not fit in 2^3 slugs")
+}
+
+func TestURLService_Blocklist(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("*.malware.example\n"), 0o600))
+	blocklist, err := NewBlocklist(path, time.Minute, logger.GetLogger("test", logger.DISABLED))
+	require.NoError(t, err)
+	store := repository.NewMemoryURLStorer()
+	err = store.Add(ctx, models.URLShortened{
+		Slug: "short",
+		URL:  "http://phishing.example/login",
+	})
+	require.NoError(t, err)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockHits := NewMockHitCounter(ctrl)
+	mockHits.EXPECT().Hit("short")
+	usvc := NewURLService(store, NewFixedLenSlugger(5), mockHits, WithBlocklist(blocklist))
+
+	_, err = usvc.Shorten(ctx, models.URLShortened{URL: "https://www.Malware.example/download"})
+	require.ErrorIs(t, err, ErrURLBlocked)
+	_, err = usvc.Add(ctx, models.URLShortened{URL: "malware.example", Slug: "pizza"})
+	require.ErrorIs(t, err, ErrURLBlocked)
+
+	// the existing shortened url is disabled once its destination is blocked
+	_, err = usvc.Resolve(ctx, "short", "")
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, []byte("*.malware.example\nphishing.example\n"), 0o600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	_, err = blocklist.Reload()
+	require.NoError(t, err)
+	_, err = usvc.Resolve(ctx, "short", "")
+	require.ErrorIs(t, err, ErrURLBlocked)
+}
+
+func TestURLService_FoldedSlugsNextToGenerated(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockSlugger := NewMockSlugger(ctrl)
+	// a base62 generated slug, case sensitive: Ab3x would be another one
+	mockSlugger.EXPECT().Slug(gomock.Any()).Return("ab3x", nil)
+	mockSlugger.EXPECT().Validate(gomock.Any()).Return(true).AnyTimes()
+	store := repository.NewMemoryURLStorer()
+	usvc := NewURLService(store, mockSlugger, NewMockHitCounter(ctrl))
+
+	generated, err := usvc.Add(ctx, models.URLShortened{URL: "http://indiependente.dev"})
+	require.NoError(t, err)
+	require.Equal(t, "ab3x", generated.Slug)
+	require.False(t, generated.FoldedSlug)
+	vanity, err := usvc.Add(ctx, models.URLShortened{URL: "http://indiependente.dev/pizza", Slug: "Ab3y"})
+	require.NoError(t, err)
+	require.Equal(t, "ab3y", vanity.Slug)
+	require.True(t, vanity.FoldedSlug)
+
+	// the vanity slug is found whatever its case
+	url, err := usvc.Get(ctx, "AB3Y")
+	require.NoError(t, err)
+	require.Equal(t, "ab3y", url.Slug)
+	// the generated slug only in its own case
+	_, err = usvc.Get(ctx, "Ab3x")
+	require.ErrorIs(t, err, ErrSlugNotFound)
+	err = usvc.Delete(ctx, "Ab3x")
+	require.ErrorIs(t, err, ErrSlugNotFound)
+	url, err = usvc.Get(ctx, "ab3x")
+	require.NoError(t, err)
+	require.Equal(t, "http://indiependente.dev", url.URL)
+
+	require.NoError(t, usvc.Delete(ctx, "AB3Y"))
+	_, err = usvc.Get(ctx, "ab3y")
+	require.ErrorIs(t, err, ErrSlugNotFound)
+}
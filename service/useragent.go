@@ -0,0 +1,48 @@
+package service
+
+import "strings"
+
+// browserFamilies map the tokens of user agents to the families of the browsers sending them.
+// Browsers built on others name them too, e.g. Edge names Chrome and Safari, so the order matters: the first match wins.
+var browserFamilies = []struct {
+	token  string
+	family string
+}{
+	{token: "edg/", family: "Edge"},
+	{token: "edga/", family: "Edge"},
+	{token: "edgios/", family: "Edge"},
+	{token: "opr/", family: "Opera"},
+	{token: "opera", family: "Opera"},
+	{token: "samsungbrowser/", family: "Samsung Internet"},
+	{token: "yabrowser/", family: "Yandex"},
+	{token: "ucbrowser/", family: "UC Browser"},
+	{token: "vivaldi/", family: "Vivaldi"},
+	{token: "brave", family: "Brave"},
+	{token: "firefox/", family: "Firefox"},
+	{token: "fxios/", family: "Firefox"},
+	{token: "crios/", family: "Chrome"},
+	{token: "chromium/", family: "Chromium"},
+	{token: "chrome/", family: "Chrome"},
+	{token: "msie ", family: "Internet Explorer"},
+	{token: "trident/", family: "Internet Explorer"},
+	{token: "safari/", family: "Safari"},
+	{token: "curl/", family: "curl"},
+	{token: "wget/", family: "Wget"},
+}
+
+// browserOther is the family of the user agents not matching any known browser.
+const browserOther = "Other"
+
+// browserFamily returns the family of the browser named by the user agent, or an empty string if there is none.
+func browserFamily(userAgent string) string {
+	if userAgent == "" {
+		return ""
+	}
+	ua := strings.ToLower(userAgent)
+	for _, b := range browserFamilies {
+		if strings.Contains(ua, b.token) {
+			return b.family
+		}
+	}
+	return browserOther
+}
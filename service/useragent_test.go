@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowserFamily(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name:      "empty",
+			userAgent: "",
+			want:      "",
+		},
+		{
+			name:      "Chrome",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want:      "Chrome",
+		},
+		{
+			name:      "Chrome on iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			want:      "Chrome",
+		},
+		{
+			name:      "Edge",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			want:      "Edge",
+		},
+		{
+			name:      "Opera",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			want:      "Opera",
+		},
+		{
+			name:      "Samsung Internet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			want:      "Samsung Internet",
+		},
+		{
+			name:      "Firefox",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			want:      "Firefox",
+		},
+		{
+			name:      "Firefox on iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/121.0 Mobile/15E148 Safari/605.1.15",
+			want:      "Firefox",
+		},
+		{
+			name:      "Safari",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+			want:      "Safari",
+		},
+		{
+			name:      "Internet Explorer",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			want:      "Internet Explorer",
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.4.0",
+			want:      "curl",
+		},
+		{
+			name:      "unknown",
+			userAgent: "SomethingElse/1.0",
+			want:      "Other",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, browserFamily(tt.userAgent))
+		})
+	}
+}
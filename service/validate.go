@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DefaultURLSchemes are the schemes of the urls that can be shortened when none is configured.
+var DefaultURLSchemes = []string{"http", "https"}
+
+// URLValidator checks that the canonical urls to shorten point somewhere safe to redirect to:
+// their scheme must be allowed, so that script and data urls are never served as redirects,
+// their host must not be a loopback, private, link local or otherwise non public address,
+// nor one of the hosts of the shortener itself, which would make redirect loops.
+// Host names are not resolved, so names pointing to private addresses are still valid,
+// the check keeps out the urls that are obviously not meant for the public.
+type URLValidator struct {
+	schemes map[string]bool
+	own     map[string]bool
+}
+
+// NewURLValidator returns a new URLValidator allowing the schemes and rejecting the own hosts of the shortener.
+// Own hosts are matched ignoring case and port, as browsers look them up.
+func NewURLValidator(schemes, ownHosts []string) URLValidator {
+	v := URLValidator{
+		schemes: make(map[string]bool, len(schemes)),
+		own:     make(map[string]bool, len(ownHosts)),
+	}
+	for _, scheme := range schemes {
+		v.schemes[strings.ToLower(scheme)] = true
+	}
+	for _, host := range ownHosts {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		ascii, err := toASCII(host)
+		if err == nil {
+			host = ascii
+		}
+		v.own[host] = true
+	}
+	return v
+}
+
+// Validate checks the canonical url, as returned by URLNormalizer.Normalize.
+// Its host is checked once mapped as browsers look it up, even if the url was not normalized.
+// Returns an error if the url is not valid.
+func (v URLValidator) Validate(canonicalURL string) error {
+	u, err := url.Parse(canonicalURL)
+	if err != nil {
+		return fmt.Errorf("could not parse url: %w", err)
+	}
+	if !v.schemes[u.Scheme] {
+		return fmt.Errorf("scheme %q not allowed", u.Scheme)
+	}
+	host := u.Hostname()
+	if !strings.Contains(host, ":") { // IPv6 addresses have no labels to map
+		// hosts are checked as browsers look them up, so that e.g. fullwidth letters can not disguise them
+		host, err = toASCII(host)
+		if err != nil {
+			return fmt.Errorf("could not map host: %w", err)
+		}
+	}
+	switch {
+	case host == "":
+		return fmt.Errorf("missing host")
+	case v.own[host]:
+		return fmt.Errorf("host %q is the shortener itself", host)
+	case host == "localhost" || strings.HasSuffix(host, ".localhost"):
+		return fmt.Errorf("host %q is local", host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return fmt.Errorf("address %s not public", ip)
+		}
+		return nil
+	}
+	if numericHost(host) {
+		// browsers read hosts like 127.1 or 0x7f000001 as addresses, which net.ParseIP does not
+		return fmt.Errorf("address %q not in dotted decimal form", host)
+	}
+	return nil
+}
+
+// publicIP reports whether the address can be reached from the internet.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// sharedAddressSpace is the range of addresses used by carrier grade NATs, see RFC 6598.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// numericHost reports whether the last label of the host is a number, which makes browsers read the host as an IPv4 address.
+func numericHost(host string) bool {
+	labels := strings.Split(host, ".")
+	last := labels[len(labels)-1]
+	if last == "" {
+		return false
+	}
+	if strings.HasPrefix(last, "0x") {
+		last = last[2:]
+		return strings.Trim(last, "0123456789abcdef") == ""
+	}
+	return strings.Trim(last, "0123456789") == ""
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLValidator_Validate(t *testing.T) {
+	t.Parallel()
+
+	validator := NewURLValidator(DefaultURLSchemes, []string{"Shrt.example:8080", "münchen.example", "sho.rt"})
+	tests := []struct {
+		name    string
+		url     string
+		wanterr bool
+	}{
+		{
+			name:    "Happy Path - http",
+			url:     "http://example.com/path",
+			wanterr: false,
+		},
+		{
+			name:    "Happy Path - https",
+			url:     "https://example.com",
+			wanterr: false,
+		},
+		{
+			name:    "Happy Path - public ipv4",
+			url:     "http://93.184.216.34/path",
+			wanterr: false,
+		},
+		{
+			name:    "Happy Path - public ipv6",
+			url:     "http://[2606:2800:220:1:248:1893:25c8:1946]/path",
+			wanterr: false,
+		},
+		{
+			name:    "Happy Path - digits in the host",
+			url:     "http://1password.com",
+			wanterr: false,
+		},
+		{
+			name:    "Sad Path - javascript",
+			url:     "javascript:alert(1)",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - data",
+			url:     "data:text/html,<script>alert(1)</script>",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - ftp",
+			url:     "ftp://example.com/file",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - missing host",
+			url:     "http:///path",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - localhost",
+			url:     "http://localhost:8080",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - localhost subdomain",
+			url:     "http://app.localhost",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - loopback",
+			url:     "http://127.0.0.1/admin",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - ipv6 loopback",
+			url:     "http://[::1]/admin",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - private",
+			url:     "http://192.168.1.1",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - link local",
+			url:     "http://169.254.169.254/latest/meta-data",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - unspecified",
+			url:     "http://0.0.0.0",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - shared address space",
+			url:     "http://100.64.0.1",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - short form address",
+			url:     "http://127.1",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - hexadecimal address",
+			url:     "http://0x7f000001",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - own host",
+			url:     "https://shrt.example/pizza",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - own internationalized host",
+			url:     "https://xn--mnchen-3ya.example/pizza",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - fullwidth localhost",
+			url:     "http://ｌｏｃａｌｈｏｓｔ/",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - loopback with ideographic full stops",
+			url:     "http://127。0。0。1/",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - fullwidth own host",
+			url:     "http://ＳＨＯ.ＲＴ/x",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - own host with trailing dot",
+			url:     "http://sho.rt./x",
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - host not valid for idna",
+			url:     "http://exa\u200dmple.com/",
+			wanterr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(tt.url)
+			require.Equal(t, tt.wanterr, err != nil)
+		})
+	}
+}
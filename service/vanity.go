@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// AlphabetVanity is made of the letters of AlphabetBase62 plus hyphens and underscores.
+	AlphabetVanity = AlphabetBase62 + "-_"
+
+	// DefaultVanityMinLen is the default minimum length of the slugs chosen by users.
+	DefaultVanityMinLen = 3
+	// DefaultVanityMaxLen is the default maximum length of the slugs chosen by users.
+	DefaultVanityMaxLen = 64
+)
+
+// DefaultVanityPolicy is the policy of the slugs chosen by users when none is configured:
+// from 3 to 64 letters, digits, hyphens and underscores, folded to lowercase.
+var DefaultVanityPolicy = VanityPolicy{
+	letters:  mustLetters(AlphabetVanity),
+	minLen:   DefaultVanityMinLen,
+	maxLen:   DefaultVanityMaxLen,
+	foldCase: true,
+}
+
+// VanityPolicy defines the rules of the slugs chosen by users, which are independent from the ones of the generated slugs.
+type VanityPolicy struct {
+	letters  map[rune]bool
+	minLen   int
+	maxLen   int
+	foldCase bool
+}
+
+// NewVanityPolicy returns a new VanityPolicy accepting slugs from minLen to maxLen letters of the charset.
+// If foldCase is set slugs are turned to lowercase before being checked and stored, so that they are case insensitive.
+// Returns an error if the charset or the lengths are not valid.
+func NewVanityPolicy(charset string, minLen, maxLen int, foldCase bool) (VanityPolicy, error) {
+	if minLen <= 0 || maxLen < minLen {
+		return VanityPolicy{}, fmt.Errorf("slug lengths from %d to %d not valid", minLen, maxLen)
+	}
+	set, err := letters(charset)
+	if err != nil {
+		return VanityPolicy{}, err
+	}
+	return VanityPolicy{
+		letters:  set,
+		minLen:   minLen,
+		maxLen:   maxLen,
+		foldCase: foldCase,
+	}, nil
+}
+
+// Normalize returns the slug as it has to be stored, reporting whether it complies with the policy.
+// Slugs must not start or end with a hyphen or an underscore, so that they stand out from the surrounding text.
+func (p VanityPolicy) Normalize(slug string) (string, bool) {
+	if p.foldCase {
+		slug = strings.ToLower(slug)
+	}
+	n := len([]rune(slug))
+	if n < p.minLen || n > p.maxLen {
+		return "", false
+	}
+	for _, c := range slug {
+		if !p.letters[c] {
+			return "", false
+		}
+	}
+	if strings.ContainsAny(slug[:1], "-_") || strings.ContainsAny(slug[len(slug)-1:], "-_") {
+		return "", false
+	}
+	return slug, true
+}
+
+// mustLetters returns the set of letters of an alphabet known to be valid.
+func mustLetters(alphabet string) map[rune]bool {
+	set, err := letters(alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVanityPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		charset string
+		minLen  int
+		maxLen  int
+		wanterr bool
+	}{
+		{
+			name:    "Happy Path",
+			charset: AlphabetVanity,
+			minLen:  1,
+			maxLen:  10,
+			wanterr: false,
+		},
+		{
+			name:    "Sad Path - zero min length",
+			charset: AlphabetVanity,
+			minLen:  0,
+			maxLen:  10,
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - max length shorter than min length",
+			charset: AlphabetVanity,
+			minLen:  5,
+			maxLen:  4,
+			wanterr: true,
+		},
+		{
+			name:    "Sad Path - charset not url safe",
+			charset: "ab/c",
+			minLen:  1,
+			maxLen:  10,
+			wanterr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVanityPolicy(tt.charset, tt.minLen, tt.maxLen, true)
+			require.Equal(t, tt.wanterr, err != nil)
+		})
+	}
+}
+
+func TestVanityPolicy_Normalize(t *testing.T) {
+	t.Parallel()
+
+	caseSensitive, err := NewVanityPolicy(AlphabetVanity, 3, 20, false)
+	require.NoError(t, err)
+	lowercase, err := NewVanityPolicy(AlphabetLowercase, 3, 20, true)
+	require.NoError(t, err)
+	tests := []struct {
+		name   string
+		policy VanityPolicy
+		slug   string
+		want   string
+		wantok bool
+	}{
+		{
+			name:   "Happy Path - default",
+			policy: DefaultVanityPolicy,
+			slug:   "team-offsite_2026",
+			want:   "team-offsite_2026",
+			wantok: true,
+		},
+		{
+			name:   "Happy Path - folded case",
+			policy: DefaultVanityPolicy,
+			slug:   "Team-Offsite",
+			want:   "team-offsite",
+			wantok: true,
+		},
+		{
+			name:   "Happy Path - kept case",
+			policy: caseSensitive,
+			slug:   "Team-Offsite",
+			want:   "Team-Offsite",
+			wantok: true,
+		},
+		{
+			name:   "Happy Path - folded before checking the charset",
+			policy: lowercase,
+			slug:   "Pizza",
+			want:   "pizza",
+			wantok: true,
+		},
+		{
+			name:   "Sad Path - too short",
+			policy: DefaultVanityPolicy,
+			slug:   "ab",
+			wantok: false,
+		},
+		{
+			name:   "Sad Path - too long",
+			policy: caseSensitive,
+			slug:   "abcdefghijklmnopqrstu",
+			wantok: false,
+		},
+		{
+			name:   "Sad Path - letter not in charset",
+			policy: DefaultVanityPolicy,
+			slug:   "team.offsite",
+			wantok: false,
+		},
+		{
+			name:   "Sad Path - leading hyphen",
+			policy: DefaultVanityPolicy,
+			slug:   "-team",
+			wantok: false,
+		},
+		{
+			name:   "Sad Path - trailing underscore",
+			policy: DefaultVanityPolicy,
+			slug:   "team_",
+			wantok: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.policy.Normalize(tt.slug)
+			require.Equal(t, tt.wantok, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}